@@ -2,63 +2,132 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/Fepozopo/gator/internal/database"
 	"github.com/google/uuid"
 )
 
+// checkFeedURL returns an error if the aggregator is not allowed to fetch the
+// given feed URL, so that such feeds are never added.
+func checkFeedURL(s *state, feedURL string) error {
+	parsedURL, err := url.Parse(feedURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", feedURL, err)
+	}
+	if err := s.fetcher.guard.checkURL(context.Background(), parsedURL); err != nil {
+		return fmt.Errorf("cannot add feed: %w", err)
+	}
+	return nil
+}
+
 // handlerAddFeed creates a new feed in the database and automatically follows it
 // for the current user. It takes two arguments: the name of the feed, and the
-// URL of the feed. The function returns an error if the feed cannot be created.
+// URL of the feed. If a feed with the URL already exists, the current user
+// follows it instead, under the given name as their own alias if it differs
+// from the feed's shared name. The feed is created and followed in one
+// transaction, so either both happen or neither does. The function returns an
+// error if the feed cannot be created or followed.
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 2 {
-		return fmt.Errorf("usage: addfeed <name> <url>")
+	feedName := cmd.args[0]
+	feedURL, err := normalizeURL(cmd.args[1])
+	if err != nil {
+		return err
 	}
 
-	feedName := cmd.args[0]
-	feedURL := cmd.args[1]
+	// Reject URLs the aggregator is not allowed to fetch
+	if err := checkFeedURL(s, feedURL); err != nil {
+		return err
+	}
 
-	// Create a new feed
-	now := time.Now()
+	var newFeed database.Feed
+	existed, alreadyFollowing, aliased := false, false, false
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		ctx := context.Background()
+		now := time.Now()
 
-	feed := database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: now,
-		UpdatedAt: now,
-		Name:      feedName,
-		Url:       feedURL,
-		UserID:    user.ID,
-	}
+		// Use the feed if it was already added, and create it otherwise
+		feed, err := q.GetFeedByUrl(ctx, feedURL)
+		switch {
+		case err == nil:
+			existed = true
+		case errors.Is(err, sql.ErrNoRows):
+			feed, err = q.CreateFeed(ctx, database.CreateFeedParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				Name:      feedName,
+				Url:       feedURL,
+				UserID:    user.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create feed: %w", err)
+			}
+		default:
+			return fmt.Errorf("failed to check feed: %w", err)
+		}
+		newFeed = feed
 
-	newFeed, err := s.db.CreateFeed(context.Background(), feed)
-	if err != nil {
-		return fmt.Errorf("failed to create feed: %w", err)
-	}
+		// Nothing is left to do if the user follows the feed already
+		if existed {
+			_, err := q.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+			if err == nil {
+				alreadyFollowing = true
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to check follow: %w", err)
+			}
+		}
 
-	// Automatically follow the created feed
-	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
-		ID:        uuid.New(),
-		CreatedAt: now,
-		UpdatedAt: now,
-		UserID:    user.ID,
-		FeedID:    feed.ID,
+		// Automatically follow the feed
+		_, err = q.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to follow feed: %w", err)
+		}
+
+		// Keep the name the user typed for a feed added under another one
+		if existed && feedName != feed.Name {
+			_, err := q.SetFeedFollowAlias(ctx, database.SetFeedFollowAliasParams{
+				Alias:     sql.NullString{String: feedName, Valid: true},
+				UpdatedAt: now,
+				UserID:    user.ID,
+				FeedUrl:   feed.Url,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to name feed: %w", err)
+			}
+			aliased = true
+		}
+		return nil
 	})
 	if err != nil {
-		// Print the new feed details
-		fmt.Print("Feed created:\n")
-		fmt.Printf("ID: %s\n", newFeed.ID)
-		fmt.Printf("Name: %s\n", newFeed.Name)
-		fmt.Printf("URL: %s\n", newFeed.Url)
-		fmt.Printf("User ID: %s\n\n", newFeed.UserID)
-		fmt.Printf("Feed created, but failed to auto-follow: %v\n", err)
-	} else {
+		return err
+	}
+
+	switch {
+	case alreadyFollowing:
+		fmt.Printf("Feed '%s' already exists and %s already follows it\n", newFeed.Name, user.Name)
+	case aliased:
+		fmt.Printf("Feed '%s' already exists and is now followed by %s as '%s'\n", newFeed.Name, user.Name, feedName)
+	case existed:
+		fmt.Printf("Feed '%s' already exists and is now followed by %s\n", newFeed.Name, user.Name)
+	default:
 		// Print the new feed details
 		fmt.Printf("Feed created and followed by %s:\n", user.Name)
 		fmt.Printf("ID: %s\n", newFeed.ID)
 		fmt.Printf("Name: %s\n", newFeed.Name)
-		fmt.Printf("URL: %s\n", newFeed.Url)
+		fmt.Printf("URL: %s\n", redactURL(newFeed.Url))
 		fmt.Printf("User ID: %s\n", newFeed.UserID)
 	}
 
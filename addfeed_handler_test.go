@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/memstore"
+)
+
+// failingFollowStore is a memstore whose transactions fail to create follows.
+type failingFollowStore struct {
+	*memstore.Store
+}
+
+// InTx runs fn with a Querier whose CreateFeedFollow fails.
+func (st failingFollowStore) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return st.Store.InTx(ctx, func(q database.Querier) error {
+		return fn(failingFollowQuerier{q})
+	})
+}
+
+// failingFollowQuerier fails every CreateFeedFollow.
+type failingFollowQuerier struct {
+	database.Querier
+}
+
+// CreateFeedFollow returns an error.
+func (failingFollowQuerier) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	return database.CreateFeedFollowRow{}, errors.New("follow failed")
+}
+
+func TestAddFeedCreatesOrFollows(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	cmd := command{name: "addfeed", args: []string{"Blog", "HTTP://Blog.Example.com/feed.xml"}}
+
+	var err error
+	captureStdout(t, func() {
+		err = handlerAddFeed(s, cmd, alice)
+	})
+	if err != nil {
+		t.Fatalf("handlerAddFeed: %v", err)
+	}
+	feed, err := s.db.GetFeedByUrl(ctx, "http://blog.example.com/feed.xml")
+	if err != nil {
+		t.Fatalf("feed was not created under its normalized URL: %v", err)
+	}
+	if feed.UserID != alice.ID {
+		t.Errorf("feed was added by %s, want alice", feed.UserID)
+	}
+
+	// Another user adding the same feed follows it instead
+	out := captureStdout(t, func() {
+		err = handlerAddFeed(s, cmd, bob)
+	})
+	if err != nil {
+		t.Fatalf("handlerAddFeed: %v", err)
+	}
+	if want := "already exists and is now followed by bob"; !strings.Contains(out, want) {
+		t.Errorf("output %q does not contain %q", out, want)
+	}
+	if count, err := s.db.CountFeedFollowers(ctx, feed.ID); err != nil || count != 2 {
+		t.Errorf("feed has %d followers (%v), want 2", count, err)
+	}
+	feeds, err := s.db.GetFeeds(ctx)
+	if err != nil || len(feeds) != 1 {
+		t.Errorf("got %d feeds (%v), want 1", len(feeds), err)
+	}
+}
+
+func TestAddFeedUnderAnotherNameKeepsTheName(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	feed := createTestFeed(t, s, alice, "Blog", "http://blog.example.com/feed.xml")
+
+	var err error
+	out := captureStdout(t, func() {
+		err = handlerAddFeed(s, command{name: "addfeed", args: []string{"Go news", feed.Url}}, bob)
+	})
+	if err != nil {
+		t.Fatalf("handlerAddFeed: %v", err)
+	}
+	if want := "Feed 'Blog' already exists and is now followed by bob as 'Go news'"; !strings.Contains(out, want) {
+		t.Errorf("output %q does not contain %q", out, want)
+	}
+
+	follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: bob.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("GetFeedFollow: %v", err)
+	}
+	if follow.Alias.String != "Go news" {
+		t.Errorf("bob follows the feed as %q, want %q", follow.Alias.String, "Go news")
+	}
+	if feed, err := s.db.GetFeedByUrl(ctx, feed.Url); err != nil || feed.Name != "Blog" {
+		t.Errorf("the shared name changed to %q (%v)", feed.Name, err)
+	}
+}
+
+func TestAddFeedRollsBackWhenFollowFails(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	s.db = failingFollowStore{s.db.(*memstore.Store)}
+
+	err := handlerAddFeed(s, command{name: "addfeed", args: []string{"Blog", "http://blog.example.com/feed.xml"}}, alice)
+	if err == nil {
+		t.Fatal("handlerAddFeed succeeded although following failed")
+	}
+	if _, err := s.db.GetFeedByUrl(context.Background(), "http://blog.example.com/feed.xml"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("the feed was kept after following it failed: %v", err)
+	}
+}
+
+func TestAddFeedRejectsInternalAddresses(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+
+	err := handlerAddFeed(s, command{name: "addfeed", args: []string{"Metadata", "http://169.254.169.254/latest"}}, alice)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("handlerAddFeed: got %v, want errBlockedAddress", err)
+	}
+}
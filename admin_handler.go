@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerAdmin handles the "admin" command, which lets admins grant admin
+// rights to other users or revoke them. The last admin cannot be revoked, so
+// there is always someone who can run the commands reserved for admins.
+func handlerAdmin(s *state, cmd command, user database.User) error {
+	if cmd.args[0] != "grant" && cmd.args[0] != "revoke" {
+		return newUsageError("unknown action %q", cmd.args[0])
+	}
+	grant := cmd.args[0] == "grant"
+	name := cmd.args[1]
+
+	err := s.db.InTx(context.Background(), func(q database.Querier) error {
+		ctx := context.Background()
+
+		target, err := q.GetUser(ctx, name)
+		if err != nil {
+			return fmt.Errorf("user '%s' does not exist", name)
+		}
+		if target.IsAdmin == grant {
+			return nil
+		}
+
+		if !grant {
+			admins, err := q.CountAdmins(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to count admins: %w", err)
+			}
+			if admins <= 1 {
+				return errors.New("cannot revoke the last admin")
+			}
+		}
+
+		_, err = q.SetUserAdmin(ctx, database.SetUserAdminParams{
+			IsAdmin:   grant,
+			UpdatedAt: time.Now(),
+			Name:      name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if grant {
+		fmt.Printf("'%s' is an admin\n", name)
+	} else {
+		fmt.Printf("'%s' is no longer an admin\n", name)
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/Fepozopo/gator/internal/database"
@@ -17,42 +16,85 @@ import (
 //
 // The function initializes a ticker with the given interval, and repeatedly
 // calls the scrapeFeeds function to fetch and process feeds. If an error
-// occurs during scraping, it logs the error to the console.
+// occurs during scraping, it logs the error to the console. If a WebSub listen
+// address is configured, it also receives content pushed by feed hubs and
+// renews hub subscriptions before their leases expire. With --prune-every,
+// posts are also pruned according to the retention settings at startup and
+// then at the given interval.
 //
 // Args:
 //
 //	s: The application state, containing database queries and configuration.
 //	cmd: The command input, which should include the time interval between
-//	     requests as an argument.
+//	     requests as an argument, and optionally the interval between prunes
+//	     as --prune-every.
 //
 // Returns:
 //
 //	An error if the time_between_reqs argument is missing or invalid.
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) < 1 {
-		return fmt.Errorf("time_between_reqs argument is required")
-	}
-
 	// Parse the argument into a time.Duration value
 	timeBetweenRequests, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("invalid time duration: %w", err)
 	}
 
+	// Parse the optional pruning interval
+	pruneEvery, ok := cmd.durationFlag("prune-every")
+	if ok && pruneEvery <= 0 {
+		return fmt.Errorf("invalid prune interval: %s", pruneEvery)
+	}
+
+	// Start the WebSub callback listener, if enabled in the config
+	listener, err := startWebSubListener(s, max(time.Hour, 2*timeBetweenRequests))
+	if err != nil {
+		return fmt.Errorf("failed to start WebSub listener: %w", err)
+	}
+	if listener != nil {
+		defer listener.Close()
+		s.websub = listener
+		fmt.Printf("Listening for WebSub callbacks on %s\n", s.cfg.WebSubListenAddr)
+	}
+
 	fmt.Printf("Collecting feeds every %s\n", timeBetweenRequests)
+	if pruneEvery > 0 {
+		fmt.Printf("Pruning posts every %s\n", pruneEvery)
+	}
 
 	ticker := time.NewTicker(timeBetweenRequests)
 	defer ticker.Stop()
 
-	// Run the scraper in a loop
+	// Run the scraper in a loop, pruning whenever the prune interval has passed
+	var lastPrune time.Time
 	for {
+		if pruneEvery > 0 && time.Since(lastPrune) >= pruneEvery {
+			lastPrune = time.Now()
+			if err := pruneAllFeeds(s); err != nil {
+				fmt.Printf("\nError pruning posts: %v\n", err)
+			}
+		}
 		if err := scrapeFeeds(s); err != nil {
 			fmt.Printf("\nError scraping feeds: %v\n", err)
 		}
+		if s.websub != nil {
+			if err := s.websub.renewExpiring(context.Background()); err != nil {
+				fmt.Printf("\nError renewing WebSub subscriptions: %v\n", err)
+			}
+		}
 		<-ticker.C
 	}
 }
 
+// pruneAllFeeds prunes the posts of every feed according to its retention.
+func pruneAllFeeds(s *state) error {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch feeds: %w", err)
+	}
+	_, err = pruneFeeds(s, feeds, false)
+	return err
+}
+
 // scrapeFeeds runs the RSS feed aggregation process, which fetches feeds from
 // the database, marks them as fetched, fetches the feed content, and saves the
 // feed items to the database as posts. If an error occurs during the process,
@@ -85,46 +127,193 @@ func scrapeFeeds(s *state) error {
 		return fmt.Errorf("failed to mark feed as fetched: %w", err)
 	}
 
+	// Load the feed's credentials, if any
+	creds, err := loadFeedCredentials(context.Background(), s, feed.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials for %s: %w", redactURL(feed.Url), err)
+	}
+
 	// Fetch the feed
-	rssFeed, err := fetchFeed(context.Background(), feed.Url)
+	rssFeed, err := s.fetcher.fetchFeed(context.Background(), feed.Url, creds)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feed from %s: %w", redactURL(feed.Url), err)
+	}
+	defer rssFeed.Close()
+
+	// Save the posts while the feed is being downloaded
+	stats, err := savePosts(s, feed, rssFeed)
+	if err != nil {
+		return fmt.Errorf("failed to ingest %s (%s): %w", redactURL(feed.Url), stats, err)
+	}
+	fmt.Printf("Fetched %s: %s\n", redactURL(feed.Url), stats)
+
+	// Subscribe to the feed's WebSub hub, if it advertises one
+	if s.websub != nil {
+		if err := s.websub.subscribeIfNeeded(context.Background(), feed, rssFeed); err != nil {
+			fmt.Printf("\nFailed to subscribe to hub for %s: %v\n", redactURL(feed.Url), err)
+		}
+	}
+
+	return nil
+}
+
+// postBatchSize is the number of posts written per multi-row insert.
+const postBatchSize = 500
+
+// ingestStats counts what happened to the items of one fetch.
+type ingestStats struct {
+	New       int
+	Updated   int
+	Unchanged int
+	Skipped   int
+}
+
+// String summarizes the counts for printing.
+func (st ingestStats) String() string {
+	return fmt.Sprintf("%d new, %d updated, %d unchanged, %d skipped", st.New, st.Updated, st.Unchanged, st.Skipped)
+}
+
+// postBatch collects posts for a single UpsertPosts call.
+type postBatch struct {
+	params database.UpsertPostsParams
+}
+
+// add appends a post to the batch.
+func (b *postBatch) add(title, postURL, description, content string, publishedAt time.Time) {
+	b.params.Ids = append(b.params.Ids, uuid.New())
+	b.params.Titles = append(b.params.Titles, title)
+	b.params.Urls = append(b.params.Urls, postURL)
+	b.params.Descriptions = append(b.params.Descriptions, description)
+	b.params.PublishedAts = append(b.params.PublishedAts, publishedAt)
+	b.params.Contents = append(b.params.Contents, content)
+}
+
+// flush writes the batch in its own transaction and resets it, adding the
+// results to the stats. Existing posts of the feed with the same URL are
+// updated if their content changed. Posts whose URL was saved from another
+// feed are left alone and count as skipped; posts that were pruned are not
+// saved again and count as unchanged.
+func (b *postBatch) flush(ctx context.Context, db storage, stats *ingestStats) error {
+	if len(b.params.Ids) == 0 {
+		return nil
+	}
+
+	var foreign int64
+	var results []bool
+	err := db.InTx(ctx, func(q database.Querier) error {
+		var err error
+		foreign, err = q.CountPostsOfOtherFeeds(ctx, database.CountPostsOfOtherFeedsParams{
+			Urls:   b.params.Urls,
+			FeedID: b.params.FeedID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check posts of other feeds: %w", err)
+		}
+		results, err = q.UpsertPosts(ctx, b.params)
+		if err != nil {
+			return fmt.Errorf("failed to save posts: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to fetch feed from %s: %w", feed.Url, err)
+		return err
+	}
+
+	// Only inserted and changed rows are returned
+	for _, inserted := range results {
+		if inserted {
+			stats.New++
+		} else {
+			stats.Updated++
+		}
+	}
+	stats.Skipped += int(foreign)
+	stats.Unchanged += len(b.params.Ids) - len(results) - int(foreign)
+
+	b.params.Ids = b.params.Ids[:0]
+	b.params.Titles = b.params.Titles[:0]
+	b.params.Urls = b.params.Urls[:0]
+	b.params.Descriptions = b.params.Descriptions[:0]
+	b.params.PublishedAts = b.params.PublishedAts[:0]
+	b.params.Contents = b.params.Contents[:0]
+	return nil
+}
+
+// savePosts saves the items of a feed to the database as posts of the given
+// feed while they are parsed. The items are written in batched multi-row
+// inserts, each in a short transaction of its own, so that no transaction is
+// held open while the feed downloads. If parsing fails part way, the batches
+// written so far are kept and counted in the returned stats. Posts of the
+// feed whose URL already exists are updated if their content changed, and
+// posts saved from another feed are left alone. Items dated before the feed's
+// maximum age are skipped, since they would only be pruned again. It is shared
+// by the polling scraper and the WebSub callback listener.
+func savePosts(s *state, feed database.Feed, rssFeed *RSSFeed) (ingestStats, error) {
+	var stats ingestStats
+	ctx := context.Background()
+
+	var oldest time.Time
+	if policy := feedRetention(s.cfg.Retention, feed); policy.MaxAgeDays > 0 {
+		oldest = time.Now().AddDate(0, 0, -policy.MaxAgeDays)
 	}
 
+	batch := &postBatch{params: database.UpsertPostsParams{
+		Now:    time.Now(),
+		FeedID: feed.ID,
+	}}
+	seen := make(map[string]bool)
+
 	// Iterate over each item in an RSS feed and parse the published date of each item
-	for _, item := range rssFeed.Items {
+	for item := range rssFeed.Items() {
 		publishedAt, err := time.Parse(time.RFC1123Z, item.PubDate)
 		if err != nil {
 			fmt.Printf("Failed to parse published date for %s: %v\n", item.Title, err)
-			publishedAt = time.Time{} // Default to zero value
+			publishedAt = time.Time{} // Default to zero value, stored as NULL
 		}
-
-		// Convert item.Description to an sql.NullString
-		description := sql.NullString{}
-		if item.Description != "" {
-			description = sql.NullString{String: item.Description, Valid: true}
+		if !publishedAt.IsZero() && publishedAt.Before(oldest) {
+			stats.Skipped++
+			continue
 		}
 
-		// Create a new post in the database
-		err = s.db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   now,
-			UpdatedAt:   now,
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: description,
-			PublishedAt: sql.NullTime{Time: publishedAt, Valid: !publishedAt.IsZero()},
-			FeedID:      feed.ID,
-		})
-		// Check if the creation is successful, a duplicate, or any other error
+		// Resolve relative links against the channel link or the feed URL, and
+		// normalize the result so the same post always gets the same URL
+		postURL, err := resolveURL(item.Link, rssFeed.Link, feed.Url)
+		if err != nil {
+			fmt.Printf("Failed to resolve link for %s: %v\n", item.Title, err)
+			stats.Skipped++
+			continue
+		}
+		postURL, err = normalizeURL(postURL)
 		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-				fmt.Printf("\nPost with URL %s already exists. Skipping.\n", item.Link)
-				continue
+			fmt.Printf("Failed to normalize link for %s: %v\n", item.Title, err)
+			stats.Skipped++
+			continue
+		}
+
+		// A URL may only be written once per statement, so keep the first
+		// occurrence within a fetch
+		if seen[postURL] {
+			stats.Skipped++
+			continue
+		}
+		seen[postURL] = true
+
+		batch.add(item.Title, postURL, item.Description, item.Content, publishedAt)
+		if len(batch.params.Ids) >= postBatchSize {
+			if err := batch.flush(ctx, s.db, &stats); err != nil {
+				return stats, err
 			}
-			return fmt.Errorf("failed to save post: %w", err)
 		}
 	}
 
-	return nil
+	// Report errors that stopped the parsing of the feed
+	if err := rssFeed.Err(); err != nil {
+		return stats, err
+	}
+
+	if err := batch.flush(ctx, s.db, &stats); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
 }
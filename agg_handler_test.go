@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSavePostsLeavesPostsOfOtherFeeds(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	user := createTestUser(t, s, "alice")
+	first := createTestFeed(t, s, user, "First", "http://first.example.com/feed.xml")
+	second := createTestFeed(t, s, user, "Second", "http://second.example.com/feed.xml")
+
+	stats, err := savePosts(s, first, newRSSFeed(strings.NewReader(testRSS(
+		[2]string{"Original", "http://example.com/shared"},
+	))))
+	if err != nil {
+		t.Fatalf("savePosts: %v", err)
+	}
+	if stats.New != 1 {
+		t.Fatalf("first feed: got %v, want 1 new", stats)
+	}
+
+	// The second feed carries the same link with another title
+	stats, err = savePosts(s, second, newRSSFeed(strings.NewReader(testRSS(
+		[2]string{"Hijacked", "http://example.com/shared"},
+		[2]string{"Own", "http://example.com/own"},
+	))))
+	if err != nil {
+		t.Fatalf("savePosts: %v", err)
+	}
+	if stats != (ingestStats{New: 1, Skipped: 1}) {
+		t.Errorf("second feed: got %v, want 1 new and 1 skipped", stats)
+	}
+
+	post, err := s.db.GetPostByUrl(ctx, "http://example.com/shared")
+	if err != nil {
+		t.Fatalf("GetPostByUrl: %v", err)
+	}
+	if post.Title != "Original" || post.FeedID != first.ID {
+		t.Errorf("post of the first feed was changed to %q of feed %s", post.Title, post.FeedID)
+	}
+
+	// The owning feed still updates its own post
+	stats, err = savePosts(s, first, newRSSFeed(strings.NewReader(testRSS(
+		[2]string{"Edited", "http://example.com/shared"},
+	))))
+	if err != nil {
+		t.Fatalf("savePosts: %v", err)
+	}
+	if stats != (ingestStats{Updated: 1}) {
+		t.Errorf("first feed again: got %v, want 1 updated", stats)
+	}
+}
+
+func TestSavePostsKeepsBatchesWrittenBeforeAParseError(t *testing.T) {
+	s := newTestState(t)
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Blog", "http://blog.example.com/feed.xml")
+
+	items := make([][2]string, postBatchSize+1)
+	for i := range items {
+		items[i] = [2]string{"Post", fmt.Sprintf("http://blog.example.com/%d", i)}
+	}
+	// The document breaks off after the first full batch
+	doc := strings.TrimSuffix(testRSS(items...), "</channel></rss>") + "<item><title>Cut"
+
+	stats, err := savePosts(s, feed, newRSSFeed(strings.NewReader(doc)))
+	if err == nil {
+		t.Fatal("savePosts succeeded on a truncated feed")
+	}
+	if stats != (ingestStats{New: postBatchSize}) {
+		t.Errorf("got %v, want %d new", stats, postBatchSize)
+	}
+	if _, err := s.db.GetPostByUrl(context.Background(), "http://blog.example.com/0"); err != nil {
+		t.Errorf("the first batch was not kept: %v", err)
+	}
+	if _, err := s.db.GetPostByUrl(context.Background(), fmt.Sprintf("http://blog.example.com/%d", postBatchSize)); err == nil {
+		t.Error("a post of the unfinished batch was saved")
+	}
+}
+
+func TestScrapeFeedsFetchesTheNextFeed(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, testRSS(
+			[2]string{"First &amp; foremost", "/first"},
+			[2]string{"Second", "/second"},
+		))
+	}))
+	defer server.Close()
+
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, alice, "Blog", server.URL+"/feed.xml")
+
+	var err error
+	out := captureStdout(t, func() {
+		err = scrapeFeeds(s)
+	})
+	if err != nil {
+		t.Fatalf("scrapeFeeds: %v", err)
+	}
+	if !strings.Contains(out, "2 new, 0 updated, 0 unchanged, 0 skipped") {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	// Relative links are resolved against the channel link
+	post, err := s.db.GetPostByUrl(ctx, "http://example.com/first")
+	if err != nil {
+		t.Fatalf("GetPostByUrl: %v", err)
+	}
+	if post.Title != "First & foremost" || post.FeedID != feed.ID {
+		t.Errorf("got post %q of feed %s", post.Title, post.FeedID)
+	}
+	fetched, err := s.db.GetFeedByUrl(ctx, feed.Url)
+	if err != nil {
+		t.Fatalf("GetFeedByUrl: %v", err)
+	}
+	if !fetched.LastFetchedAt.Valid {
+		t.Error("the feed was not marked as fetched")
+	}
+
+	// Fetching again changes nothing
+	out = captureStdout(t, func() {
+		err = scrapeFeeds(s)
+	})
+	if err != nil {
+		t.Fatalf("scrapeFeeds: %v", err)
+	}
+	if !strings.Contains(out, "0 new, 0 updated, 2 unchanged, 0 skipped") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestScrapeFeedsRejectsInternalFeeds(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	createTestFeed(t, s, alice, "Metadata", "http://169.254.169.254/latest")
+
+	if err := scrapeFeeds(s); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("scrapeFeeds: got %v, want errBlockedAddress", err)
+	}
+}
@@ -2,21 +2,38 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/Fepozopo/gator/internal/database"
+	"github.com/google/uuid"
 )
 
-// handlerBrowse prints a list of the posts for the currently logged-in user.
-// If a single argument is provided, it is interpreted as an integer and used
-// as a limit for the number of posts to retrieve. If no argument is provided, a
-// default limit of 2 is used. The retrieved posts are printed with their title,
-// URL, description (if any), and publication date.
+// postRecord is a post as printed by "browse" in structured output formats.
+type postRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description *string    `json:"description"`
+	PublishedAt *time.Time `json:"published_at"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	Read        bool       `json:"read"`
+}
+
+// handlerBrowse prints a list of the unread posts for the currently logged-in
+// user. If a numeric argument is provided, it is used as a limit for the
+// number of posts to retrieve. If no limit is provided, a default limit of 2
+// is used. With --all, posts that were already read are included and marked
+// as such. With --folder, only posts from the feeds in that folder are
+// listed. The retrieved posts are printed with their title, URL, description
+// (if any), and publication date.
 func handlerBrowse(s *state, cmd command, user database.User) error {
 	limit := 2
-	// Try to convert the first argument to an integer
 	if len(cmd.args) > 0 {
+		// Try to convert the argument to an integer
 		parsedLimit, err := strconv.Atoi(cmd.args[0])
 		if err != nil || parsedLimit <= 0 {
 			return fmt.Errorf("invalid limit: %v", cmd.args[0])
@@ -24,18 +41,60 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 		limit = parsedLimit
 	}
 
+	unreadOnly := !cmd.boolFlag("all")
+	var folder sql.NullString
+	if name, ok := cmd.stringFlag("folder"); ok {
+		found, err := findFolder(s, user, name)
+		if err != nil {
+			return err
+		}
+		folder = sql.NullString{String: found.Name, Valid: true}
+	}
+
 	// Retrieve a list of posts for a specific user from the database
 	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
+		UserID:     user.ID,
+		UnreadOnly: unreadOnly,
+		Folder:     folder,
+		Limit:      int32(limit),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to get posts: %w", err)
 	}
 
+	if s.output.structured() {
+		records := make([]postRecord, 0, len(posts))
+		for _, post := range posts {
+			record := postRecord{
+				ID:     post.ID,
+				Title:  post.Title,
+				URL:    post.Url,
+				FeedID: post.FeedID,
+				Read:   post.Read,
+			}
+			if post.Description.Valid {
+				record.Description = &post.Description.String
+			}
+			if post.PublishedAt.Valid {
+				record.PublishedAt = &post.PublishedAt.Time
+			}
+			records = append(records, record)
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	if len(posts) == 0 && unreadOnly {
+		fmt.Print("No unread posts. Use 'browse --all' to include read posts.\n")
+		return nil
+	}
+
 	// Print a list of the posts
 	for _, post := range posts {
-		fmt.Printf("\n\n\n========================================\nTitle: %s\n\n* URL: %s\n", post.Title, post.Url)
+		title := post.Title
+		if post.Read {
+			title += " (read)"
+		}
+		fmt.Printf("\n\n\n========================================\nTitle: %s\n\n* URL: %s\n* ID: %s\n", title, post.Url, post.ID)
 		if post.Description.Valid {
 			fmt.Printf("\n* Description: %s\n", post.Description.String)
 		}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+func TestBrowseListsUnreadPostsOfFollowedFeeds(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	followed := createTestFeed(t, s, alice, "Followed", "http://followed.example.com/feed.xml")
+	other := createTestFeed(t, s, alice, "Other", "http://other.example.com/feed.xml")
+	followTestFeed(t, s, alice, followed)
+
+	for feed, items := range map[database.Feed][][2]string{
+		followed: {{"Read", "http://followed.example.com/read"}, {"Unread", "http://followed.example.com/unread"}},
+		other:    {{"Unfollowed", "http://other.example.com/post"}},
+	} {
+		if _, err := savePosts(s, feed, newRSSFeed(strings.NewReader(testRSS(items...)))); err != nil {
+			t.Fatalf("savePosts: %v", err)
+		}
+	}
+	_, err := s.db.MarkPostsRead(context.Background(), database.MarkPostsReadParams{
+		ReadAt:  time.Now(),
+		UserID:  alice.ID,
+		PostUrl: sql.NullString{String: "http://followed.example.com/read", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("MarkPostsRead: %v", err)
+	}
+
+	s.output = outputJSON
+	tests := []struct {
+		name string
+		cmd  command
+		want map[string]bool
+	}{
+		{"unread", command{name: "browse", args: []string{"10"}}, map[string]bool{"Unread": false}},
+		{"all", command{name: "browse", args: []string{"10"}, flags: map[string]any{"all": true}},
+			map[string]bool{"Read": true, "Unread": false}},
+		{"limit", command{name: "browse", args: []string{"1"}, flags: map[string]any{"all": true}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			out := captureStdout(t, func() {
+				err = handlerBrowse(s, tt.cmd, alice)
+			})
+			if err != nil {
+				t.Fatalf("handlerBrowse: %v", err)
+			}
+
+			var records []postRecord
+			if err := json.Unmarshal([]byte(out), &records); err != nil {
+				t.Fatalf("decoding %q: %v", out, err)
+			}
+			if tt.want == nil {
+				if len(records) != 1 {
+					t.Errorf("got %d posts, want 1", len(records))
+				}
+				return
+			}
+			got := make(map[string]bool)
+			for _, record := range records {
+				got[record.Title] = record.Read
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got posts %v, want %v", got, tt.want)
+			}
+			for title, read := range tt.want {
+				if gotRead, ok := got[title]; !ok || gotRead != read {
+					t.Errorf("got posts %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBrowseRejectsInvalidLimit(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+
+	if err := handlerBrowse(s, command{name: "browse", args: []string{"zero"}}, alice); err == nil {
+		t.Error("handlerBrowse accepted a limit that is not a number")
+	}
+}
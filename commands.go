@@ -1,33 +1,447 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-// command represents a CLI command.
+// command represents a CLI command as given on the command line: the
+// positional arguments in args, and the values of the flags the command
+// declares in flags.
 type command struct {
+	name  string
+	args  []string
+	flags map[string]any
+}
+
+// flagKind is the type of value a flag takes.
+type flagKind int
+
+const (
+	// boolFlag is set by giving the flag, without a value.
+	boolFlag flagKind = iota
+	// stringFlag takes any value.
+	stringFlag
+	// intFlag takes a whole number.
+	intFlag
+	// durationFlag takes a duration such as 30s or 1h.
+	durationFlag
+	// stringsFlag takes any value and may be given more than once.
+	stringsFlag
+)
+
+// flagSpec declares a flag a command takes.
+type flagSpec struct {
+	name string
+	kind flagKind
+	// value names the flag's value in help, e.g. "<feed_url>".
+	value string
+	// choices, if set, are the only values the flag accepts.
+	choices []string
+	usage   string
+}
+
+// commandSpec declares a command: how it is used, the flags it takes, and the
+// handler that runs it.
+type commandSpec struct {
 	name string
-	args []string
+	// summary is the one-line description shown by "gator help".
+	summary string
+	// usage holds one synopsis of the positional arguments for each form of
+	// the command. The flags are added to the synopsis of commands with a
+	// single form; commands with several forms list them in their usage.
+	usage []string
+	// description is the longer help shown by "gator <cmd> --help".
+	description string
+	// minArgs and maxArgs bound the number of positional arguments. A
+	// negative maxArgs allows any number.
+	minArgs int
+	maxArgs int
+	flags   []flagSpec
+	handler func(*state, command) error
+}
+
+// globalFlags are the flags every command accepts, as listed in help. They
+// are taken out of the command line before it is parsed, by
+// extractOutputOption.
+var globalFlags = []flagSpec{
+	{name: "output", kind: stringFlag, value: "<format>", usage: "Print listings as text, json, jsonl, csv or table"},
 }
 
-// commands holds all registered command handlers.
+// commands holds all registered commands.
 type commands struct {
-	handlers map[string]func(*state, command) error
+	specs map[string]commandSpec
+}
+
+// usageError is returned for command lines that do not match the usage of
+// the command. The command's usage is shown with the error.
+type usageError struct {
+	msg string
 }
 
-// register adds a new command handler to the commands map.
-func (c *commands) register(name string, handler func(*state, command) error) {
-	if c.handlers == nil {
-		c.handlers = make(map[string]func(*state, command) error)
+// Error returns the message of the usage error.
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// newUsageError returns a usage error with a formatted message.
+func newUsageError(format string, a ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// register adds a command to the registry.
+func (c *commands) register(spec commandSpec) {
+	if c.specs == nil {
+		c.specs = make(map[string]commandSpec)
 	}
-	c.handlers[name] = handler
+	c.specs[spec.name] = spec
 }
 
-// run executes a command if it exists in the handlers map.
+// run executes a command if it is registered. The number of arguments is
+// checked again, so handlers can index the arguments the spec requires even
+// for commands that were not built by parse. Usage errors returned by the
+// handler are shown with the command's usage.
 func (c *commands) run(s *state, cmd command) error {
-	handler, exists := c.handlers[cmd.name]
+	spec, exists := c.specs[cmd.name]
+	if !exists {
+		return c.unknownCommandError(cmd.name)
+	}
+	if err := spec.checkArgs(cmd.args); err != nil {
+		return err
+	}
+	err := spec.handler(s, cmd)
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return spec.usageError(usageErr.msg)
+	}
+	return err
+}
+
+// parse parses the command line arguments into a command. Flags may be given
+// as "--flag value" or "--flag=value", before or after positional arguments;
+// arguments after "--" are always positional. "-h" or "--help" anywhere
+// turns the command line into a "help" command for the named command.
+func (c *commands) parse(args []string) (command, error) {
+	cmd := command{flags: make(map[string]any)}
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		cmd.name = "help"
+		return cmd, nil
+	}
+
+	cmd.name = args[0]
+	spec, exists := c.specs[cmd.name]
 	if !exists {
-		return fmt.Errorf("unknown command: %s", cmd.name)
+		return cmd, c.unknownCommandError(cmd.name)
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			cmd.args = append(cmd.args, args[i+1:]...)
+			i = len(args)
+		case arg == "-h" || arg == "--help":
+			return command{name: "help", args: []string{cmd.name}, flags: cmd.flags}, nil
+		case strings.HasPrefix(arg, "--"):
+			n, err := spec.parseFlag(cmd.flags, args[i:])
+			if err != nil {
+				return cmd, err
+			}
+			i += n - 1
+		default:
+			cmd.args = append(cmd.args, arg)
+		}
+	}
+
+	return cmd, spec.checkArgs(cmd.args)
+}
+
+// checkArgs returns a usage error if the number of positional arguments is
+// outside the bounds of the command.
+func (spec commandSpec) checkArgs(args []string) error {
+	if len(args) < spec.minArgs {
+		return spec.usageError("missing arguments")
+	}
+	if spec.maxArgs >= 0 && len(args) > spec.maxArgs {
+		return spec.usageError(fmt.Sprintf("too many arguments: %s", strings.Join(args[spec.maxArgs:], " ")))
+	}
+	return nil
+}
+
+// parseFlag parses the flag of the command at the start of args into values
+// and returns the number of arguments it took.
+func (spec commandSpec) parseFlag(values map[string]any, args []string) (int, error) {
+	fail := func(format string, a ...any) (int, error) {
+		return 0, spec.usageError(fmt.Sprintf(format, a...))
+	}
+
+	name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+	i := slices.IndexFunc(spec.flags, func(f flagSpec) bool { return f.name == name })
+	if i < 0 {
+		names := make([]string, len(spec.flags))
+		for i, flag := range spec.flags {
+			names[i] = flag.name
+		}
+		if suggestion := suggest(name, names); suggestion != "" {
+			return fail("unknown flag --%s; did you mean --%s?", name, suggestion)
+		}
+		return fail("unknown flag --%s", name)
+	}
+	flag := spec.flags[i]
+
+	if flag.kind == boolFlag {
+		set := true
+		if hasValue {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fail("invalid value %q for --%s: use true or false", value, name)
+			}
+			set = parsed
+		}
+		values[name] = set
+		return 1, nil
+	}
+
+	n := 1
+	if !hasValue {
+		if len(args) < 2 {
+			return fail("missing value for --%s", name)
+		}
+		value = args[1]
+		n = 2
+	}
+	if len(flag.choices) > 0 && !slices.Contains(flag.choices, value) {
+		return fail("invalid value %q for --%s: use %s", value, name, joinChoices(flag.choices))
+	}
+
+	switch flag.kind {
+	case stringFlag:
+		values[name] = value
+	case stringsFlag:
+		previous, _ := values[name].([]string)
+		values[name] = append(previous, value)
+	case intFlag:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fail("invalid value %q for --%s: must be a whole number", value, name)
+		}
+		values[name] = parsed
+	case durationFlag:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fail("invalid value %q for --%s: must be a duration such as 30s, 10m or 1h", value, name)
+		}
+		values[name] = parsed
+	}
+	return n, nil
+}
+
+// boolFlag reports whether a bool flag was given.
+func (cmd command) boolFlag(name string) bool {
+	set, _ := cmd.flags[name].(bool)
+	return set
+}
+
+// stringFlag returns the value of a string flag and whether it was given.
+func (cmd command) stringFlag(name string) (string, bool) {
+	value, ok := cmd.flags[name].(string)
+	return value, ok
+}
+
+// intFlag returns the value of an int flag and whether it was given.
+func (cmd command) intFlag(name string) (int, bool) {
+	value, ok := cmd.flags[name].(int)
+	return value, ok
+}
+
+// durationFlag returns the value of a duration flag and whether it was given.
+func (cmd command) durationFlag(name string) (time.Duration, bool) {
+	value, ok := cmd.flags[name].(time.Duration)
+	return value, ok
+}
+
+// stringsFlag returns the values of a flag that may be given more than once,
+// in the order they were given.
+func (cmd command) stringsFlag(name string) []string {
+	values, _ := cmd.flags[name].([]string)
+	return values
+}
+
+// unknownCommandError returns the error for a command name that is not
+// registered, suggesting the closest registered name.
+func (c *commands) unknownCommandError(name string) error {
+	names := make([]string, 0, len(c.specs))
+	for known := range c.specs {
+		names = append(names, known)
+	}
+	if suggestion := suggest(name, names); suggestion != "" {
+		return fmt.Errorf("unknown command %q; did you mean %q?\nRun 'gator help' for a list of commands.", name, suggestion)
+	}
+	return fmt.Errorf("unknown command %q\nRun 'gator help' for a list of commands.", name)
+}
+
+// usageError returns an error with the given message followed by the
+// command's usage.
+func (spec commandSpec) usageError(msg string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\nUsage:\n", msg)
+	for _, line := range spec.synopses() {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	fmt.Fprintf(&b, "Run 'gator %s --help' for more information.", spec.name)
+	return errors.New(b.String())
+}
+
+// synopses returns the usage lines of the command, one for each form.
+func (spec commandSpec) synopses() []string {
+	usage := spec.usage
+	if len(usage) == 0 {
+		usage = []string{""}
+	}
+
+	lines := make([]string, len(usage))
+	for i, args := range usage {
+		line := "gator " + spec.name
+		if args != "" {
+			line += " " + args
+		}
+		if len(usage) == 1 {
+			for _, flag := range spec.flags {
+				line += " [" + flag.synopsis() + "]"
+				if flag.kind == stringsFlag {
+					line += "..."
+				}
+			}
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// synopsis returns the flag as shown in usage lines, e.g. "--feed <feed_url>".
+func (flag flagSpec) synopsis() string {
+	if flag.kind == boolFlag {
+		return "--" + flag.name
+	}
+	return "--" + flag.name + " " + flag.value
+}
+
+// printHelp prints the list of commands, or the help of a single command if
+// a name is given.
+func (c *commands) printHelp(w io.Writer, name string) error {
+	if name != "" {
+		spec, exists := c.specs[name]
+		if !exists {
+			return c.unknownCommandError(name)
+		}
+		spec.printHelp(w)
+		return nil
+	}
+
+	names := make([]string, 0, len(c.specs))
+	for name := range c.specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprint(w, "gator is an RSS feed aggregator for the command line.\n\n")
+	fmt.Fprint(w, "Usage:\n  gator <command> [arguments] [flags]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, c.specs[name].summary)
+	}
+	tw.Flush()
+	fmt.Fprint(w, "\nGlobal flags:\n")
+	printFlags(w, globalFlags)
+	fmt.Fprint(w, "\nRun 'gator help <command>' or 'gator <command> --help' for more information on a command.\n")
+	return nil
+}
+
+// printHelp prints the help of a single command.
+func (spec commandSpec) printHelp(w io.Writer) {
+	fmt.Fprintf(w, "%s\n\nUsage:\n", spec.summary)
+	for _, line := range spec.synopses() {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	if spec.description != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.Trim(spec.description, "\n"))
+	}
+	if len(spec.flags) > 0 {
+		fmt.Fprint(w, "\nFlags:\n")
+		printFlags(w, spec.flags)
+	}
+	fmt.Fprint(w, "\nGlobal flags:\n")
+	printFlags(w, globalFlags)
+}
+
+// printFlags prints a table of flags with their usage.
+func printFlags(w io.Writer, flags []flagSpec) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, flag := range flags {
+		usage := flag.usage
+		if flag.kind == stringsFlag {
+			usage += " (may be repeated)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", flag.synopsis(), usage)
+	}
+	tw.Flush()
+}
+
+// joinChoices lists choices in prose, e.g. "a, b or c".
+func joinChoices(choices []string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
+
+// suggest returns the candidate closest to name, for "did you mean"
+// suggestions, or "" if none is close enough to be a likely typo.
+func suggest(name string, candidates []string) string {
+	best, bestDistance := "", 0
+	for _, candidate := range candidates {
+		distance := editDistance(name, candidate)
+		if strings.HasPrefix(candidate, name) && name != "" {
+			// Count an abbreviation as a close match
+			distance = min(distance, 1)
+		}
+		if distance > max(1, len(name)/3) {
+			continue
+		}
+		if best == "" || distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b: the number
+// of single-character insertions, deletions and substitutions that turn one
+// into the other.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
 	}
-	return handler(s, cmd)
+	return previous[len(rb)]
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCommands returns a registry with a command taking every kind of
+// flag.
+func newTestCommands(handler func(*state, command) error) *commands {
+	cmds := &commands{}
+	cmds.register(commandSpec{
+		name:    "search",
+		usage:   []string{"<query>..."},
+		minArgs: 1,
+		maxArgs: 2,
+		flags: []flagSpec{
+			{name: "feed", kind: stringFlag, value: "<feed_url>"},
+			{name: "format", kind: stringFlag, value: "<format>", choices: []string{"short", "long"}},
+			{name: "limit", kind: intFlag, value: "<n>"},
+			{name: "every", kind: durationFlag, value: "<duration>"},
+			{name: "tag", kind: stringsFlag, value: "<tag>"},
+			{name: "all", kind: boolFlag},
+		},
+		handler: handler,
+	})
+	return cmds
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantArgs  []string
+		wantFlags map[string]any
+		wantErr   string
+	}{
+		{"no arguments", nil, nil, nil, ""},
+		{"positional", []string{"search", "go"}, []string{"go"}, map[string]any{}, ""},
+		{"flag with separate value", []string{"search", "--feed", "http://a.example.com", "go"},
+			[]string{"go"}, map[string]any{"feed": "http://a.example.com"}, ""},
+		{"flag with value after =", []string{"search", "go", "--limit=5"},
+			[]string{"go"}, map[string]any{"limit": 5}, ""},
+		{"value containing =", []string{"search", "--feed=http://a.example.com/?a=b", "go"},
+			[]string{"go"}, map[string]any{"feed": "http://a.example.com/?a=b"}, ""},
+		{"bool flag", []string{"search", "--all", "go"}, []string{"go"}, map[string]any{"all": true}, ""},
+		{"bool flag set to false", []string{"search", "--all=false", "go"}, []string{"go"}, map[string]any{"all": false}, ""},
+		{"duration", []string{"search", "go", "--every", "90s"}, []string{"go"}, map[string]any{"every": 90 * time.Second}, ""},
+		{"repeated strings flag", []string{"search", "--tag", "a", "go", "--tag=b"},
+			[]string{"go"}, map[string]any{"tag": []string{"a", "b"}}, ""},
+		{"repeated string flag keeps the last", []string{"search", "--feed", "a", "--feed", "b", "go"},
+			[]string{"go"}, map[string]any{"feed": "b"}, ""},
+		{"arguments after --", []string{"search", "--all", "--", "--limit", "-h"},
+			[]string{"--limit", "-h"}, map[string]any{"all": true}, ""},
+		{"help flag", []string{"search", "--limit", "3", "--help"}, nil, nil, ""},
+		{"choice", []string{"search", "go", "--format", "long"}, []string{"go"}, map[string]any{"format": "long"}, ""},
+
+		{"unknown command", []string{"serch"}, nil, nil, `unknown command "serch"; did you mean "search"?`},
+		{"unknown flag with suggestion", []string{"search", "go", "--limt", "5"}, nil, nil, "unknown flag --limt; did you mean --limit?"},
+		{"abbreviated flag", []string{"search", "go", "--fo=short"}, nil, nil, "did you mean --format?"},
+		{"unknown flag", []string{"search", "go", "--verbose"}, nil, nil, "unknown flag --verbose\n"},
+		{"missing value", []string{"search", "go", "--feed"}, nil, nil, "missing value for --feed"},
+		{"invalid int", []string{"search", "go", "--limit", "five"}, nil, nil, `invalid value "five" for --limit`},
+		{"invalid duration", []string{"search", "go", "--every=soon"}, nil, nil, `invalid value "soon" for --every`},
+		{"invalid bool", []string{"search", "go", "--all=maybe"}, nil, nil, `invalid value "maybe" for --all`},
+		{"invalid choice", []string{"search", "go", "--format", "wide"}, nil, nil, "use short or long"},
+		{"missing arguments", []string{"search", "--all"}, nil, nil, "missing arguments\nUsage:\n  gator search <query>..."},
+		{"too many arguments", []string{"search", "a", "b", "c"}, nil, nil, "too many arguments: c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := newTestCommands(nil).parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if tt.wantFlags == nil {
+				if cmd.name != "help" {
+					t.Errorf("got command %q, want help", cmd.name)
+				}
+				return
+			}
+			if !reflect.DeepEqual(cmd.args, tt.wantArgs) {
+				t.Errorf("got args %q, want %q", cmd.args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(cmd.flags, tt.wantFlags) {
+				t.Errorf("got flags %v, want %v", cmd.flags, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestRunChecksArguments(t *testing.T) {
+	called := false
+	cmds := newTestCommands(func(*state, command) error {
+		called = true
+		return newUsageError("bad query")
+	})
+
+	err := cmds.run(nil, command{name: "search"})
+	if err == nil || !strings.Contains(err.Error(), "missing arguments") {
+		t.Errorf("run without arguments: got %v, want a usage error", err)
+	}
+	if called {
+		t.Error("the handler ran without the arguments it requires")
+	}
+
+	err = cmds.run(nil, command{name: "search", args: []string{"go"}})
+	if err == nil || !strings.Contains(err.Error(), "bad query\nUsage:\n") {
+		t.Errorf("run: got %v, want the handler's usage error with the usage", err)
+	}
+}
+
+func TestRegisteredCommands(t *testing.T) {
+	cmds := newCommands()
+	for name, spec := range cmds.specs {
+		if spec.handler == nil || spec.summary == "" {
+			t.Errorf("command %s has no handler or summary", name)
+		}
+		if spec.maxArgs >= 0 && spec.maxArgs < spec.minArgs {
+			t.Errorf("command %s takes at most %d but at least %d arguments", name, spec.maxArgs, spec.minArgs)
+		}
+	}
+
+	// Handlers index the arguments their spec requires
+	if _, err := cmds.parse([]string{"feed", "edit"}); err == nil || !strings.Contains(err.Error(), "gator feed edit <feed_url>") {
+		t.Errorf("parse: got %v, want the usage of feed", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := []string{"addfeed", "browse", "feed", "feeds", "follow", "following", "unfollow"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"folow", "follow"},
+		{"brwse", "browse"},
+		{"fed", "feed"},
+		{"feedz", "feed"},
+		{"follo", "follow"},
+		{"addfed", "addfeed"},
+		{"unfolow", "unfollow"},
+		{"x", ""},
+		{"register", ""},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.name, names); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"feed", "feed", 0},
+		{"feed", "feeds", 1},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
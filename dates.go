@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDate parses a date given on the command line. It accepts a calendar
+// date ("2024-05-01") or timestamp ("2024-05-01T15:04:05Z"), or an age
+// relative to now in days or weeks ("30d", "2w"). The second result reports
+// whether only a day was given, so callers can treat the bound as covering
+// the whole day.
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+
+	if days, ok := parseAge(value); ok {
+		return time.Now().AddDate(0, 0, -days), false, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("invalid date %q: use YYYY-MM-DD, an RFC 3339 timestamp, or an age like 30d or 2w", value)
+}
+
+// parseAge parses an age in days or weeks ("30d", "2w") and returns it in
+// days.
+func parseAge(value string) (int, bool) {
+	unit := strings.TrimLeft(value, "0123456789")
+	if unit != "d" && unit != "w" {
+		return 0, false
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(value, unit))
+	if err != nil {
+		return 0, false
+	}
+	if unit == "w" {
+		days *= 7
+	}
+	return days, true
+}
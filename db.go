@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	database "github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/memstore"
+	gatorsqlite "github.com/Fepozopo/gator/internal/sqlite"
+)
+
+// sqliteQueriesFS holds the SQLite translations of the queries in
+// sql/queries.
+//
+//go:embed sql/sqlite/queries/*.sql
+var sqliteQueriesFS embed.FS
+
+// sqliteOptions are added to every SQLite DSN. Foreign keys enforce the
+// ON DELETE CASCADE clauses of the schema, and WAL mode with a busy timeout
+// lets "agg" write while other commands read.
+const sqliteOptions = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+// dbConn is an open database connection together with the dialect it speaks.
+type dbConn struct {
+	sqlDB   *sql.DB
+	dialect goose.Dialect
+
+	// sqlite translates the generated queries when dialect is SQLite.
+	sqlite *gatorsqlite.DB
+}
+
+// connectDatabase opens the database named by the db_url setting. URLs with
+// the sqlite: or file: scheme open a SQLite database file; anything else is
+// passed to the PostgreSQL driver.
+func connectDatabase(dbURL string) (*dbConn, error) {
+	path, isSQLite, err := sqlitePath(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSQLite {
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return nil, err
+		}
+		return &dbConn{sqlDB: db, dialect: goose.DialectPostgres}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?"+sqliteOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := fs.Sub(sqliteQueriesFS, "sql/sqlite/queries")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	translated, err := gatorsqlite.New(db, queries)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load SQLite queries: %w", err)
+	}
+
+	return &dbConn{sqlDB: db, dialect: goose.DialectSQLite3, sqlite: translated}, nil
+}
+
+// sqlitePath returns the file path of a SQLite db_url, with a leading "~"
+// expanded to the home directory. It reports false for other URLs.
+func sqlitePath(dbURL string) (string, bool, error) {
+	scheme, rest, ok := strings.Cut(dbURL, ":")
+	if !ok || (scheme != "sqlite" && scheme != "file") {
+		return "", false, nil
+	}
+
+	// sqlite:///abs/path and sqlite://~/path are URLs; sqlite:path is not
+	path := rest
+	if strings.HasPrefix(rest, "//") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid db_url: %w", err)
+		}
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return "", false, fmt.Errorf("invalid db_url %q: missing database file path", dbURL)
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return path, true, nil
+}
+
+// queries returns the generated queries for the connection.
+func (c *dbConn) queries() *database.Queries {
+	if c.sqlite != nil {
+		return database.New(c.sqlite)
+	}
+	return database.New(c.sqlDB)
+}
+
+// withTx returns the generated queries running inside tx.
+func (c *dbConn) withTx(tx *sql.Tx) *database.Queries {
+	if c.sqlite != nil {
+		return database.New(c.sqlite.WithTx(tx))
+	}
+	return database.New(tx)
+}
+
+// isUniqueViolation reports whether err was caused by a unique constraint,
+// for any storage.
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, memstore.ErrUniqueViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
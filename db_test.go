@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestCommand parses and runs a command line like main does, and returns
+// what it printed.
+func runTestCommand(t *testing.T, s *state, args ...string) string {
+	t.Helper()
+
+	cmds := newCommands()
+	cmd, err := cmds.parse(args)
+	if err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	out := captureStdout(t, func() {
+		err = cmds.run(s, cmd)
+	})
+	if err != nil {
+		t.Fatalf("running %v: %v", args, err)
+	}
+	return out
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTestState(t)
+	s.cfg.Retention.MaxPostsPerFeed = 1
+	setTestInput(t, "correct horse battery")
+
+	// The first user registers, and becomes an admin
+	runTestCommand(t, s, "register", "alice")
+	runTestCommand(t, s, "addfeed", "Burrows", "HTTP://Blog.Example.com/feed.xml")
+	feed, err := s.db.GetFeedByUrl(ctx, "http://blog.example.com/feed.xml")
+	if err != nil {
+		t.Fatalf("GetFeedByUrl: %v", err)
+	}
+
+	now := time.Now()
+	saveTestPosts(t, s, feed, datedRSS(now.Add(-time.Hour), [2]string{"Gophers dig tunnels", "http://blog.example.com/tunnels"}))
+	saveTestPosts(t, s, feed, datedRSS(now.Add(-48*time.Hour), [2]string{"Crabs walk sideways", "http://blog.example.com/crabs"}))
+	saveTestPosts(t, s, feed, datedRSS(now.Add(-72*time.Hour), [2]string{"Gophers sleep in winter", "http://blog.example.com/winter"}))
+
+	out := runTestCommand(t, s, "search", "gophers")
+	if !strings.Contains(out, "Gophers dig tunnels") || !strings.Contains(out, "Gophers sleep in winter") || strings.Contains(out, "Crabs") {
+		t.Errorf("search gophers printed %q", out)
+	}
+
+	// The search index keeps matching the right posts after a post is
+	// deleted and the database is compacted
+	if _, err := s.conn.sqlDB.ExecContext(ctx, "DELETE FROM posts WHERE url = 'http://blog.example.com/tunnels'"); err != nil {
+		t.Fatalf("deleting a post: %v", err)
+	}
+	if _, err := s.conn.sqlDB.ExecContext(ctx, "VACUUM"); err != nil {
+		t.Fatalf("VACUUM: %v", err)
+	}
+	if out := runTestCommand(t, s, "search", "winter"); !strings.Contains(out, "Gophers sleep in winter") {
+		t.Errorf("search winter after VACUUM printed %q", out)
+	}
+	if out := runTestCommand(t, s, "search", "sideways"); !strings.Contains(out, "Crabs walk sideways") {
+		t.Errorf("search sideways after VACUUM printed %q", out)
+	}
+
+	// Pruning keeps the newest post, which is the only one left to find
+	if out := runTestCommand(t, s, "prune"); !strings.Contains(out, "Pruned 1 post(s) in total.") {
+		t.Errorf("prune printed %q", out)
+	}
+	if out := runTestCommand(t, s, "search", "gophers"); strings.Contains(out, "winter") {
+		t.Errorf("search gophers after pruning printed %q", out)
+	}
+	if out := runTestCommand(t, s, "search", "sideways"); !strings.Contains(out, "Crabs walk sideways") {
+		t.Errorf("search sideways after pruning printed %q", out)
+	}
+
+	// Pruned posts are not saved again
+	if stats := saveTestPosts(t, s, feed, datedRSS(now.Add(-72*time.Hour), [2]string{"Gophers sleep in winter", "http://blog.example.com/winter"})); stats.New != 0 {
+		t.Errorf("saving the pruned post again: got %v, want nothing new", stats)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerExport handles the "export" command, which writes the feeds the
+// current user follows to standard output as an OPML file that other feed
+// readers can import. Feeds are listed under the user's aliases for them, and
+// folders become categories.
+func handlerExport(s *state, cmd command, user database.User) error {
+	if cmd.args[0] != "opml" {
+		return newUsageError("unknown format %q", cmd.args[0])
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), database.GetFeedFollowsForUserParams{
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch following: %w", err)
+	}
+
+	return writeOPML(os.Stdout, fmt.Sprintf("Feeds followed by %s", user.Name), follows)
+}
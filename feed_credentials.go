@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/secrets"
+	"github.com/google/uuid"
+)
+
+// redacted replaces secret values whenever credentials are printed.
+const redacted = "[redacted]"
+
+// feedCredentials holds the authentication and extra headers sent with every
+// request for a feed. They are stored encrypted in the feed_credentials table.
+type feedCredentials struct {
+	Username    string            `json:"username,omitempty"`
+	Password    string            `json:"password,omitempty"`
+	BearerToken string            `json:"bearer_token,omitempty"`
+	Cookie      string            `json:"cookie,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// apply sets the credentials and extra headers on an outgoing request. Extra
+// headers are applied first so that the dedicated credentials take precedence.
+func (c *feedCredentials) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+	if c.Cookie != "" {
+		req.Header.Set("Cookie", c.Cookie)
+	}
+}
+
+// headerNames returns the names of the headers apply sets.
+func (c *feedCredentials) headerNames() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.Headers)+2)
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	if c.Username != "" || c.Password != "" || c.BearerToken != "" {
+		names = append(names, "Authorization")
+	}
+	if c.Cookie != "" {
+		names = append(names, "Cookie")
+	}
+	return names
+}
+
+// isEmpty reports whether no credentials or headers are set.
+func (c *feedCredentials) isEmpty() bool {
+	return c.Username == "" && c.Password == "" && c.BearerToken == "" && c.Cookie == "" && len(c.Headers) == 0
+}
+
+// String describes the credentials with every secret value redacted, so they
+// can be printed safely.
+func (c *feedCredentials) String() string {
+	if c == nil || c.isEmpty() {
+		return "none"
+	}
+
+	var parts []string
+	if c.Username != "" || c.Password != "" {
+		parts = append(parts, fmt.Sprintf("basic auth (user %s, password %s)", c.Username, redacted))
+	}
+	if c.BearerToken != "" {
+		parts = append(parts, "bearer token "+redacted)
+	}
+	if c.Cookie != "" {
+		parts = append(parts, "cookie "+redacted)
+	}
+
+	names := make([]string, 0, len(c.Headers))
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("header %s: %s", name, redacted))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// loadFeedCredentials returns the decrypted credentials of a feed, or nil if
+// the feed has none.
+func loadFeedCredentials(ctx context.Context, s *state, feedID uuid.UUID) (*feedCredentials, error) {
+	row, err := s.db.GetFeedCredentials(ctx, feedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get feed credentials: %w", err)
+	}
+
+	plaintext, err := secrets.Decrypt(s.cfg.CredentialsKey, row.Ciphertext, feedID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt feed credentials: %w", err)
+	}
+
+	var creds feedCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode feed credentials: %w", err)
+	}
+	return &creds, nil
+}
+
+// saveFeedCredentials encrypts and stores the credentials of a feed, deleting
+// them if they are empty.
+func saveFeedCredentials(ctx context.Context, s *state, feedID uuid.UUID, creds *feedCredentials) error {
+	if creds.isEmpty() {
+		if err := s.db.DeleteFeedCredentials(ctx, feedID); err != nil {
+			return fmt.Errorf("failed to delete feed credentials: %w", err)
+		}
+		return nil
+	}
+
+	if err := ensureCredentialsKey(s); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to encode feed credentials: %w", err)
+	}
+	ciphertext, err := secrets.Encrypt(s.cfg.CredentialsKey, plaintext, feedID[:])
+	if err != nil {
+		return fmt.Errorf("failed to encrypt feed credentials: %w", err)
+	}
+
+	now := time.Now()
+	err = s.db.UpsertFeedCredentials(ctx, database.UpsertFeedCredentialsParams{
+		FeedID:     feedID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Ciphertext: ciphertext,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save feed credentials: %w", err)
+	}
+	return nil
+}
+
+// ensureCredentialsKey generates a credentials key and writes it to the config
+// if there is none yet.
+func ensureCredentialsKey(s *state) error {
+	if s.cfg.CredentialsKey != "" {
+		return nil
+	}
+
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := s.cfg.SetCredentialsKey(key); err != nil {
+		return fmt.Errorf("failed to save credentials key: %w", err)
+	}
+	fmt.Print("Generated a new credentials key in the config file. Keep a copy of it: stored credentials cannot be decrypted without it.\n")
+	return nil
+}
+
+// redactURL returns the URL with any password in its user info replaced, so
+// it can be printed safely. Unparseable URLs are returned unchanged.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerFeed handles the "feed" command, which fixes the name or URL of a
+// feed, or deletes a feed with all its posts. Unlike "rename-follow", which
+// only changes the name the current user sees, editing a feed changes it for
+// every follower.
+func handlerFeed(s *state, cmd command, user database.User) error {
+	action, feedURL := cmd.args[0], cmd.args[1]
+	switch action {
+	case "edit":
+		if cmd.boolFlag("yes") {
+			return newUsageError("--yes only applies to 'feed delete'")
+		}
+		return editFeed(s, user, feedURL, cmd)
+	case "delete":
+		if cmd.boolFlag("keep-credentials") {
+			return newUsageError("--keep-credentials only applies to 'feed edit'")
+		}
+		if _, ok := cmd.stringFlag("name"); ok {
+			return newUsageError("--name only applies to 'feed edit'")
+		}
+		if _, ok := cmd.stringFlag("url"); ok {
+			return newUsageError("--url only applies to 'feed edit'")
+		}
+		return deleteFeed(s, user, feedURL, cmd.boolFlag("yes"))
+	default:
+		return newUsageError("unknown action %q", action)
+	}
+}
+
+// findManagedFeed looks up a feed by the URL given on the command line,
+// making sure the current user added it or is an admin.
+func findManagedFeed(s *state, user database.User, rawURL string) (database.Feed, error) {
+	feedURL, err := normalizeURL(rawURL)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("feed not found: %w", err)
+	}
+	if feed.UserID != user.ID && !user.IsAdmin {
+		return database.Feed{}, errors.New("only the user who added the feed or an admin can change it")
+	}
+	return feed, nil
+}
+
+// countOtherFollowers returns the number of users other than the given user
+// who follow a feed.
+func countOtherFollowers(s *state, user database.User, feed database.Feed) (int, error) {
+	ctx := context.Background()
+	count, err := s.db.CountFeedFollowers(ctx, feed.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	_, err = s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+	if err == nil {
+		count--
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to check follow: %w", err)
+	}
+	return int(count), nil
+}
+
+// editFeed changes the name or URL of a feed. A new URL is checked like the
+// URL of a new feed, and the feed is fetched from it on the next run of "agg".
+// The feed's credentials are deleted when the new URL is on another host, so
+// that they are not sent to it, unless --keep-credentials is given.
+func editFeed(s *state, user database.User, rawURL string, cmd command) error {
+	feed, err := findManagedFeed(s, user, rawURL)
+	if err != nil {
+		return err
+	}
+
+	params := database.UpdateFeedParams{
+		Name:      feed.Name,
+		Url:       feed.Url,
+		UpdatedAt: time.Now(),
+		ID:        feed.ID,
+	}
+	name, hasName := cmd.stringFlag("name")
+	newURL, hasURL := cmd.stringFlag("url")
+	keepCredentials := cmd.boolFlag("keep-credentials")
+	if !hasName && !hasURL {
+		return newUsageError("nothing to change: give --name or --url")
+	}
+	if keepCredentials && !hasURL {
+		return newUsageError("--keep-credentials only applies with --url")
+	}
+	if hasName {
+		if name == "" {
+			return errors.New("feed name cannot be empty")
+		}
+		params.Name = name
+	}
+	if hasURL {
+		params.Url, err = normalizeURL(newURL)
+		if err != nil {
+			return err
+		}
+		if err := checkFeedURL(s, params.Url); err != nil {
+			return err
+		}
+	}
+
+	oldHost, newHost := urlHost(feed.Url), urlHost(params.Url)
+	removedCredentials := false
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		ctx := context.Background()
+		if err := q.UpdateFeed(ctx, params); err != nil {
+			return err
+		}
+		if params.Url == feed.Url {
+			return nil
+		}
+
+		// Credentials for the old host are not sent to another one
+		if !strings.EqualFold(oldHost, newHost) && !keepCredentials {
+			_, err := q.GetFeedCredentials(ctx, feed.ID)
+			switch {
+			case err == nil:
+				if err := q.DeleteFeedCredentials(ctx, feed.ID); err != nil {
+					return err
+				}
+				removedCredentials = true
+			case !errors.Is(err, sql.ErrNoRows):
+				return err
+			}
+		}
+
+		// The hub subscription is for the old URL; agg subscribes again
+		// after fetching the feed from its new URL
+		sub, err := q.GetWebSubSubscriptionByFeedID(ctx, feed.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return q.DeleteWebSubSubscription(ctx, sub.ID)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("a feed with the URL %s already exists", redactURL(params.Url))
+		}
+		return fmt.Errorf("failed to update feed: %w", err)
+	}
+
+	fmt.Printf("Updated feed: %s (%s)\n", params.Name, redactURL(params.Url))
+	if removedCredentials {
+		fmt.Printf("Removed the feed's credentials, which were for %s; set them again with 'feedauth' if %s needs them.\n", oldHost, newHost)
+	}
+	others, err := countOtherFollowers(s, user, feed)
+	if err != nil {
+		return err
+	}
+	if others > 0 {
+		fmt.Printf("Note: %d other user(s) follow this feed and see the change.\n", others)
+	}
+	return nil
+}
+
+// deleteFeed deletes a feed with its posts, follows and credentials, after
+// warning about other users who follow it.
+func deleteFeed(s *state, user database.User, rawURL string, yes bool) error {
+	feed, err := findManagedFeed(s, user, rawURL)
+	if err != nil {
+		return err
+	}
+
+	others, err := countOtherFollowers(s, user, feed)
+	if err != nil {
+		return err
+	}
+	if others > 0 {
+		fmt.Printf("Warning: %d other user(s) follow '%s' and will lose it.\n", others, feed.Name)
+	}
+	if !yes {
+		ok, err := confirm(fmt.Sprintf("Delete feed '%s' with all its posts?", feed.Name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+
+	if err := s.db.DeleteFeed(context.Background(), feed.ID); err != nil {
+		return fmt.Errorf("failed to delete feed: %w", err)
+	}
+
+	fmt.Printf("Deleted feed '%s'\n", feed.Name)
+	return nil
+}
+
+// urlHost returns the host, with the port if any, of a URL that was already
+// normalized.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
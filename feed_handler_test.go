@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+func TestEditFeedURLDropsCredentialsForAnotherHost(t *testing.T) {
+	tests := []struct {
+		name      string
+		newURL    string
+		keep      bool
+		wantCreds bool
+	}{
+		{"same host", "http://blog.example.com/atom.xml", false, true},
+		{"another port", "http://blog.example.com:8080/feed.xml", false, false},
+		{"another host", "http://attacker.example.net/feed.xml", false, false},
+		{"another host, kept", "http://mirror.example.com/feed.xml", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestState(t)
+			alice := createTestUser(t, s, "alice")
+			feed := createTestFeed(t, s, alice, "Blog", "http://blog.example.com/feed.xml")
+			err := s.db.UpsertFeedCredentials(ctx, database.UpsertFeedCredentialsParams{
+				FeedID:     feed.ID,
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+				Ciphertext: []byte("sealed"),
+			})
+			if err != nil {
+				t.Fatalf("UpsertFeedCredentials: %v", err)
+			}
+
+			cmd := command{name: "feed", args: []string{"edit", feed.Url}, flags: map[string]any{
+				"url":              tt.newURL,
+				"keep-credentials": tt.keep,
+			}}
+			captureStdout(t, func() {
+				err = handlerFeed(s, cmd, alice)
+			})
+			if err != nil {
+				t.Fatalf("handlerFeed: %v", err)
+			}
+
+			if _, err := s.db.GetFeedByUrl(ctx, tt.newURL); err != nil {
+				t.Errorf("the feed was not moved to %s: %v", tt.newURL, err)
+			}
+			_, err = s.db.GetFeedCredentials(ctx, feed.ID)
+			if gotCreds := err == nil; gotCreds != tt.wantCreds {
+				t.Errorf("credentials kept: %v, want %v", gotCreds, tt.wantCreds)
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetFeedCredentials: %v", err)
+			}
+		})
+	}
+}
+
+func TestEditFeedKeepCredentialsNeedsURL(t *testing.T) {
+	s := newTestState(t)
+	alice := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, alice, "Blog", "http://blog.example.com/feed.xml")
+
+	cmd := command{name: "feed", args: []string{"edit", feed.Url}, flags: map[string]any{
+		"name":             "Renamed",
+		"keep-credentials": true,
+	}}
+	var usageErr *usageError
+	if err := handlerFeed(s, cmd, alice); !errors.As(err, &usageErr) {
+		t.Errorf("handlerFeed: got %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerFeedAuth handles the "feedauth" command, which manages the
+// credentials and extra headers sent when fetching a feed. Only the user who
+// added the feed can change or view them. Secret values are encrypted in the
+// database and never printed.
+func handlerFeedAuth(s *state, cmd command, user database.User) error {
+	feedURL, err := normalizeURL(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	action := cmd.args[1]
+	args := cmd.args[2:]
+
+	// Look up the feed by URL
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("feed not found: %w", err)
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added the feed can manage its credentials")
+	}
+
+	creds, err := loadFeedCredentials(context.Background(), s, feed.ID)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		creds = &feedCredentials{}
+	}
+
+	switch {
+	case action == "show" && len(args) == 0:
+		fmt.Printf("Credentials for %s: %s\n", redactURL(feed.Url), creds)
+		return nil
+	case action == "basic" && len(args) == 2:
+		creds.Username, creds.Password = args[0], args[1]
+		creds.BearerToken = ""
+	case action == "bearer" && len(args) == 1:
+		creds.BearerToken = args[0]
+		creds.Username, creds.Password = "", ""
+	case action == "cookie" && len(args) == 1:
+		creds.Cookie = args[0]
+	case action == "header" && len(args) == 2:
+		if creds.Headers == nil {
+			creds.Headers = make(map[string]string)
+		}
+		creds.Headers[http.CanonicalHeaderKey(args[0])] = args[1]
+	case action == "unheader" && len(args) == 1:
+		delete(creds.Headers, http.CanonicalHeaderKey(args[0]))
+	case action == "clear" && len(args) == 0:
+		creds = &feedCredentials{}
+	default:
+		return newUsageError("unknown action %q or wrong number of arguments", action)
+	}
+
+	if err := saveFeedCredentials(context.Background(), s, feed.ID, creds); err != nil {
+		return err
+	}
+
+	fmt.Printf("Credentials for %s updated: %s\n", redactURL(feed.Url), creds)
+	return nil
+}
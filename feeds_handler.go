@@ -3,26 +3,53 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 )
 
+// feedRecord is a feed as printed by "feeds" in structured output formats.
+type feedRecord struct {
+	Name     string  `json:"name"`
+	Alias    *string `json:"alias"`
+	URL      string  `json:"url"`
+	UserName string  `json:"user_name"`
+}
+
 // handlerFeeds prints all feeds with their associated user names to the console.
-// It takes no arguments, and returns an error if any arguments are passed.
+// Feeds the current user follows under an alias are shown with the alias first.
 func handlerFeeds(s *state, cmd command) error {
-	// Ensure no arguments are passed
-	if len(cmd.args) > 0 {
-		return fmt.Errorf("usage: feeds (no arguments allowed)")
-	}
+	// Aliases are only shown to a logged-in user
+	currentUser, _ := sessionUser(s)
 
 	// Fetch all feeds with their associated user names
-	feeds, err := s.db.GetAllFeedsWithUsers(context.Background())
+	feeds, err := s.db.GetAllFeedsWithUsers(context.Background(), currentUser.Name)
 	if err != nil {
 		return fmt.Errorf("failed to fetch feeds: %w", err)
 	}
 
+	if s.output.structured() {
+		records := make([]feedRecord, 0, len(feeds))
+		for _, feed := range feeds {
+			record := feedRecord{
+				Name:     feed.FeedName,
+				URL:      redactURL(feed.FeedUrl),
+				UserName: feed.UserName,
+			}
+			if feed.Alias.Valid {
+				record.Alias = &feed.Alias.String
+			}
+			records = append(records, record)
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
 	// Print the feeds to the console
 	fmt.Print("Feeds:\n")
 	for _, feed := range feeds {
-		fmt.Printf("Feed Name: %s\nFeed URL: %s\nUser Name: %s\n\n", feed.FeedName, feed.FeedUrl, feed.UserName)
+		name := feed.FeedName
+		if feed.Alias.Valid {
+			name = fmt.Sprintf("%s (shared name: %s)", feed.Alias.String, feed.FeedName)
+		}
+		fmt.Printf("Feed Name: %s\nFeed URL: %s\nUser Name: %s\n\n", name, redactURL(feed.FeedUrl), feed.UserName)
 	}
 
 	return nil
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	config "github.com/Fepozopo/gator/internal/config"
+)
+
+// Default settings of the feed fetcher, used when the config leaves them empty.
+const (
+	defaultConnectTimeout      = 10 * time.Second
+	defaultReadTimeout         = 30 * time.Second
+	defaultFetchTimeout        = 2 * time.Minute
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 4
+	defaultContactURL          = "https://github.com/Fepozopo/gator"
+)
+
+// fetcher is the HTTP client shared by everything that fetches feeds. It
+// reuses connections across fetches and enforces connect, read and overall
+// timeouts so a single hung server cannot stall the aggregator. Connections
+// to internal addresses are refused, see addressGuard.
+type fetcher struct {
+	client      *http.Client
+	guard       *addressGuard
+	userAgent   string
+	readTimeout time.Duration
+}
+
+// newFetcher builds the shared fetcher from the HTTP settings in the config.
+// It returns an error if a duration, the proxy URL or the CA bundle is invalid.
+func newFetcher(cfg config.HTTPConfig) (*fetcher, error) {
+	connectTimeout, err := parseDurationOr(cfg.ConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connect_timeout: %w", err)
+	}
+	readTimeout, err := parseDurationOr(cfg.ReadTimeout, defaultReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read_timeout: %w", err)
+	}
+	timeout, err := parseDurationOr(cfg.Timeout, defaultFetchTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	// Use the configured proxy, falling back to the environment
+	proxy := http.ProxyFromEnvironment
+	proxyAddr := ""
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy_url %q", cfg.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+		proxyAddr = proxyDialAddr(proxyURL)
+	}
+
+	guard, err := newAddressGuard(cfg.AllowedNetworks, proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.CABundle)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	maxIdleConnsPerHost := cfg.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   guard.control,
+	}
+	dial := dialer.DialContext
+	if proxyAddr != "" {
+		// The configured proxy is usually internal, so connections to it,
+		// and only to it, skip the address check
+		proxyDialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == proxyAddr {
+				return proxyDialer.DialContext(ctx, network, addr)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		contactURL := cfg.ContactURL
+		if contactURL == "" {
+			contactURL = defaultContactURL
+		}
+		userAgent = fmt.Sprintf("gator/%s (+%s)", version, contactURL)
+	}
+
+	return &fetcher{
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       timeout,
+			CheckRedirect: guard.checkRedirect,
+		},
+		guard:       guard,
+		userAgent:   userAgent,
+		readTimeout: readTimeout,
+	}, nil
+}
+
+// get sends a GET request for the URL with the fetcher's User-Agent and the
+// given feed credentials applied. The returned body fails any read that waits
+// longer than the read timeout for data; the caller must close it.
+func (f *fetcher) get(ctx context.Context, rawURL string, creds *feedCredentials) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// Redirects to other hosts drop the headers carrying the credentials
+	ctx = context.WithValue(ctx, credentialHeadersKey{}, creds.headerNames())
+
+	// Create an HTTP request with context
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := f.guard.checkURL(ctx, req.URL); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Set User-Agent header
+	req.Header.Set("User-Agent", f.userAgent)
+
+	// Apply the feed's credentials and extra headers
+	creds.apply(req)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := &readTimeoutBody{
+		ReadCloser: resp.Body,
+		timeout:    f.readTimeout,
+		cancel:     cancel,
+	}
+	body.timer = time.AfterFunc(f.readTimeout, func() {
+		body.timedOut.Store(true)
+		cancel()
+	})
+	body.timer.Stop() // Started by each Read
+	resp.Body = body
+	return resp, nil
+}
+
+// readTimeoutBody is a response body that cancels its request if a single
+// read waits longer than the timeout. Time spent by the caller between reads
+// does not count, so slow consumers are not mistaken for slow servers.
+type readTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+	cancel   context.CancelFunc
+}
+
+// Read reads from the body, failing if no data arrives in time.
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && b.timedOut.Load() {
+		return n, fmt.Errorf("read timeout: no data received for %s", b.timeout)
+	}
+	return n, err
+}
+
+// Close closes the body and releases its request context.
+func (b *readTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// proxyDialAddr returns the address the HTTP transport dials to connect to a
+// proxy: its host and port, with the port defaulting by scheme.
+func proxyDialAddr(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// newTLSConfig returns a TLS config trusting the system roots plus the
+// certificates in the given PEM bundle. It returns nil if no bundle is set.
+func newTLSConfig(caBundle string) (*tls.Config, error) {
+	if caBundle == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in ca_bundle %s", caBundle)
+	}
+
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// parseDurationOr parses a duration string, returning def if it is empty.
+func parseDurationOr(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// folderRecord is a folder as printed by "folder ls" in structured output
+// formats.
+type folderRecord struct {
+	Name        string `json:"name"`
+	FeedCount   int64  `json:"feed_count"`
+	UnreadCount int64  `json:"unread_count"`
+}
+
+// findFolder looks up one of the user's folders by the name given on the
+// command line.
+func findFolder(s *state, user database.User, name string) (database.Folder, error) {
+	folder, err := s.db.GetFolderByName(context.Background(), database.GetFolderByNameParams{
+		UserID: user.ID,
+		Name:   strings.TrimSpace(name),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return folder, fmt.Errorf("no folder named '%s'", name)
+	}
+	if err != nil {
+		return folder, fmt.Errorf("failed to get folder: %w", err)
+	}
+	return folder, nil
+}
+
+// handlerFolder handles the "folder" command, which sorts the feeds the
+// current user follows into named folders. Each followed feed is in at most
+// one folder. Removing a folder keeps its feeds, which are then no longer in
+// a folder. "browse" and "following" take --folder to show only one folder.
+func handlerFolder(s *state, cmd command, user database.User) error {
+	action, args := cmd.args[0], cmd.args[1:]
+	switch {
+	case action == "add" && len(args) == 1:
+		return addFolder(s, user, args[0])
+	case action == "mv" && len(args) >= 2:
+		return moveToFolder(s, user, args[:len(args)-1], args[len(args)-1])
+	case action == "rm" && len(args) == 1:
+		return removeFolder(s, user, args[0])
+	case action == "rm" && len(args) > 1:
+		return removeFromFolder(s, user, args[0], args[1:])
+	case action == "ls" && len(args) == 0:
+		return listFolders(s, user)
+	case action == "add" || action == "mv" || action == "rm" || action == "ls":
+		return newUsageError("wrong number of arguments for 'folder %s'", action)
+	default:
+		return newUsageError("unknown action %q", action)
+	}
+}
+
+// addFolder creates an empty folder.
+func addFolder(s *state, user database.User, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("folder name cannot be empty")
+	}
+
+	now := time.Now()
+	_, err := s.db.CreateFolder(context.Background(), database.CreateFolderParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Name:      name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("folder '%s' already exists", name)
+		}
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	fmt.Printf("Created folder '%s'\n", name)
+	return nil
+}
+
+// moveToFolder moves followed feeds into a folder, out of any folder they
+// were in before.
+func moveToFolder(s *state, user database.User, feedURLs []string, name string) error {
+	folder, err := findFolder(s, user, name)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		for _, arg := range feedURLs {
+			feedURL, err := normalizeURL(arg)
+			if err != nil {
+				return err
+			}
+			moved, err := q.SetFeedFollowFolder(context.Background(), database.SetFeedFollowFolderParams{
+				FolderID:  uuid.NullUUID{UUID: folder.ID, Valid: true},
+				UpdatedAt: time.Now(),
+				UserID:    user.ID,
+				FeedUrl:   feedURL,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to move feed: %w", err)
+			}
+			if moved == 0 {
+				return fmt.Errorf("you are not following %s", redactURL(feedURL))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %d feed(s) to '%s'\n", len(feedURLs), folder.Name)
+	return nil
+}
+
+// removeFolder deletes a folder. The feeds in it are still followed.
+func removeFolder(s *state, user database.User, name string) error {
+	removed, err := s.db.DeleteFolder(context.Background(), database.DeleteFolderParams{
+		UserID: user.ID,
+		Name:   strings.TrimSpace(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("no folder named '%s'", name)
+	}
+
+	fmt.Printf("Deleted folder '%s'; its feeds are still followed\n", name)
+	return nil
+}
+
+// removeFromFolder takes followed feeds out of a folder without unfollowing
+// them.
+func removeFromFolder(s *state, user database.User, name string, feedURLs []string) error {
+	folder, err := findFolder(s, user, name)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		for _, arg := range feedURLs {
+			feedURL, err := normalizeURL(arg)
+			if err != nil {
+				return err
+			}
+			removed, err := q.RemoveFeedFollowFromFolder(context.Background(), database.RemoveFeedFollowFromFolderParams{
+				UpdatedAt: time.Now(),
+				UserID:    user.ID,
+				FolderID:  uuid.NullUUID{UUID: folder.ID, Valid: true},
+				FeedUrl:   feedURL,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to remove feed from folder: %w", err)
+			}
+			if removed == 0 {
+				return fmt.Errorf("%s is not in folder '%s'", redactURL(feedURL), folder.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d feed(s) from '%s'\n", len(feedURLs), folder.Name)
+	return nil
+}
+
+// listFolders prints the user's folders with the number of feeds and unread
+// posts in each.
+func listFolders(s *state, user database.User) error {
+	folders, err := s.db.GetFoldersForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get folders: %w", err)
+	}
+	if s.output.structured() {
+		records := make([]folderRecord, 0, len(folders))
+		for _, folder := range folders {
+			records = append(records, folderRecord{
+				Name:        folder.Name,
+				FeedCount:   folder.FeedCount,
+				UnreadCount: folder.UnreadCount,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	if len(folders) == 0 {
+		fmt.Print("No folders. Create one with 'folder add <folder>'.\n")
+		return nil
+	}
+
+	for _, folder := range folders {
+		fmt.Printf("* %s (%d feed(s), %d unread)\n", folder.Name, folder.FeedCount, folder.UnreadCount)
+	}
+	return nil
+}
@@ -16,10 +16,10 @@ import (
 // exist. The feed follow record is then created, and a success message is
 // printed with the user and feed details.
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("usage: follow <feed_url>")
+	feedURL, err := normalizeURL(cmd.args[0])
+	if err != nil {
+		return err
 	}
-	feedURL := cmd.args[0]
 
 	// Look up the feed by URL
 	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
@@ -2,27 +2,70 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"os"
 
 	"github.com/Fepozopo/gator/internal/database"
 )
 
-// handlerFollowing handles the "following" command, which prints all feeds that the current user is following.
+// followRecord is a followed feed as printed by "following" in structured
+// output formats.
+type followRecord struct {
+	Name        string  `json:"name"`
+	URL         string  `json:"url"`
+	Folder      *string `json:"folder"`
+	UnreadCount int64   `json:"unread_count"`
+}
+
+// handlerFollowing handles the "following" command, which prints all feeds that the current user is following
+// along with the number of posts in each that the user has not read. Feeds in folders are grouped under the
+// folder's name. With --folder, only the feeds in that folder are printed.
 func handlerFollowing(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("usage: following (no arguments allowed)")
+	params := database.GetFeedFollowsForUserParams{UserID: user.ID}
+	if name, ok := cmd.stringFlag("folder"); ok {
+		folder, err := findFolder(s, user, name)
+		if err != nil {
+			return err
+		}
+		params.Folder = sql.NullString{String: folder.Name, Valid: true}
 	}
 
 	// Fetch feed follows for the user
-	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), params)
 	if err != nil {
 		return fmt.Errorf("failed to fetch following: %w", err)
 	}
 
+	if s.output.structured() {
+		records := make([]followRecord, 0, len(follows))
+		for _, follow := range follows {
+			record := followRecord{
+				Name:        follow.FeedName,
+				URL:         redactURL(follow.FeedUrl),
+				UnreadCount: follow.UnreadCount,
+			}
+			if follow.FolderName.Valid {
+				record.Folder = &follow.FolderName.String
+			}
+			records = append(records, record)
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
 	// Print the feed names
-	fmt.Printf("Feeds followed by %s:\n", user.Name)
+	if params.Folder.Valid {
+		fmt.Printf("Feeds followed by %s in '%s':\n", user.Name, params.Folder.String)
+	} else {
+		fmt.Printf("Feeds followed by %s:\n", user.Name)
+	}
+	var folder sql.NullString
 	for _, follow := range follows {
-		fmt.Printf("* %s\n", follow.FeedName)
+		if !params.Folder.Valid && follow.FolderName != folder {
+			folder = follow.FolderName
+			fmt.Printf("\n%s:\n", folder.String)
+		}
+		fmt.Printf("* %s (%d unread)\n", follow.FeedName, follow.UnreadCount)
 	}
 	return nil
 }
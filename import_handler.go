@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Results of importing a single feed.
+const (
+	importAdded    = "added"
+	importFollowed = "followed"
+	importSkipped  = "skipped"
+	importFailed   = "failed"
+)
+
+// handlerImport handles the "import" command, which follows the feeds listed
+// in an OPML file exported from another feed reader. Feeds that do not exist
+// yet are added, and categories in the file become folders. Each feed is
+// imported on its own, so feeds that are already followed, or appear twice,
+// are skipped and a feed that cannot be imported does not stop the rest.
+func handlerImport(s *state, cmd command, user database.User) error {
+	if cmd.args[0] != "opml" {
+		return newUsageError("unknown format %q", cmd.args[0])
+	}
+
+	file, err := os.Open(cmd.args[1])
+	if err != nil {
+		return fmt.Errorf("failed to open OPML file: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := parseOPML(file)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no feeds found in %s", cmd.args[1])
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		result, err := importFeed(s, user, entry)
+		if err != nil {
+			result = importFailed
+		}
+		counts[result]++
+
+		line := fmt.Sprintf("%-9s %s", result, redactURL(entry.URL))
+		if entry.Folder != "" {
+			line += fmt.Sprintf(" (%s)", entry.Folder)
+		}
+		if result == importSkipped {
+			line += ": already followed"
+		}
+		if err != nil {
+			line += fmt.Sprintf(": %v", err)
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\nImported %d feed(s): %d added, %d followed, %d skipped, %d failed\n",
+		len(entries), counts[importAdded], counts[importFollowed], counts[importSkipped], counts[importFailed])
+	if counts[importFailed] > 0 {
+		return fmt.Errorf("%d feed(s) could not be imported", counts[importFailed])
+	}
+	return nil
+}
+
+// importFeed follows the feed of an OPML entry, adding the feed first if it
+// does not exist yet, and moves it into the entry's folder. If the feed
+// exists under another name, the entry's name becomes the user's alias for
+// it. Nothing is changed for feeds the user already follows.
+func importFeed(s *state, user database.User, entry opmlEntry) (string, error) {
+	feedURL, err := normalizeURL(entry.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := checkFeedURL(s, feedURL); err != nil {
+		return "", err
+	}
+	name := entry.Name
+	if name == "" {
+		name = feedURL
+	}
+
+	result := importFollowed
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		ctx := context.Background()
+		now := time.Now()
+
+		feed, err := q.GetFeedByUrl(ctx, feedURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			feed, err = q.CreateFeed(ctx, database.CreateFeedParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				Name:      name,
+				Url:       feedURL,
+				UserID:    user.ID,
+			})
+			result = importAdded
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add feed: %w", err)
+		}
+
+		_, err = q.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+		if err == nil {
+			result = importSkipped
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check follow: %w", err)
+		}
+
+		_, err = q.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to follow feed: %w", err)
+		}
+
+		if entry.Name != "" && entry.Name != feed.Name {
+			_, err := q.SetFeedFollowAlias(ctx, database.SetFeedFollowAliasParams{
+				Alias:     sql.NullString{String: entry.Name, Valid: true},
+				UpdatedAt: now,
+				UserID:    user.ID,
+				FeedUrl:   feedURL,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rename feed: %w", err)
+			}
+		}
+
+		if entry.Folder == "" {
+			return nil
+		}
+		folder, err := q.GetFolderByName(ctx, database.GetFolderByNameParams{UserID: user.ID, Name: entry.Folder})
+		if errors.Is(err, sql.ErrNoRows) {
+			folder, err = q.CreateFolder(ctx, database.CreateFolderParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				UserID:    user.ID,
+				Name:      entry.Folder,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create folder: %w", err)
+		}
+		_, err = q.SetFeedFollowFolder(ctx, database.SetFeedFollowFolderParams{
+			FolderID:  uuid.NullUUID{UUID: folder.ID, Valid: true},
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedUrl:   feedURL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to move feed to folder: %w", err)
+		}
+		return nil
+	})
+	return result, err
+}
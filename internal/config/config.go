@@ -6,14 +6,75 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const configFileName = ".gatorconfig.json"
 
 // Config represents the JSON structure of the configuration file.
 type Config struct {
-	DbURL           string `json:"db_url"`
+	DbURL string `json:"db_url"`
+	// CurrentUserName is the name of the logged-in user. It is only shown to
+	// the user; the session token decides who is logged in.
 	CurrentUserName string `json:"current_user_name"`
+
+	// SessionToken is the secret token of the current login session, which
+	// ends at SessionExpiresAt.
+	SessionToken     string     `json:"session_token,omitempty"`
+	SessionExpiresAt *time.Time `json:"session_expires_at,omitempty"`
+
+	// WebSubListenAddr is the address (e.g. ":8080") the aggregator listens on
+	// for WebSub callbacks. WebSub is disabled when it is empty.
+	WebSubListenAddr string `json:"websub_listen_addr,omitempty"`
+	// WebSubCallbackURL is the public base URL under which hubs can reach the
+	// listener (e.g. "https://gator.example.com").
+	WebSubCallbackURL string `json:"websub_callback_url,omitempty"`
+
+	// CredentialsKey is the base64-encoded key used to encrypt feed
+	// credentials and WebSub secrets stored in the database.
+	CredentialsKey string `json:"credentials_key,omitempty"`
+
+	// HTTP configures the client used to fetch feeds.
+	HTTP HTTPConfig `json:"http"`
+
+	// Retention configures how long posts are kept before "prune" deletes
+	// them. Feeds can override it with the "retention" command.
+	Retention RetentionConfig `json:"retention"`
+}
+
+// RetentionConfig represents the default retention of posts. Zero fields do
+// not limit how long posts are kept.
+type RetentionConfig struct {
+	// MaxAgeDays is the number of days after publication that posts are kept.
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// MaxPostsPerFeed is the number of most recent posts kept for each feed.
+	MaxPostsPerFeed int `json:"max_posts_per_feed,omitempty"`
+}
+
+// HTTPConfig represents the settings of the HTTP client used to fetch feeds.
+// Durations are strings accepted by time.ParseDuration, e.g. "10s". Empty
+// fields use the defaults.
+type HTTPConfig struct {
+	ConnectTimeout      string `json:"connect_timeout,omitempty"`
+	ReadTimeout         string `json:"read_timeout,omitempty"`
+	Timeout             string `json:"timeout,omitempty"`
+	MaxIdleConns        int    `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host,omitempty"`
+	// ProxyURL is the proxy used for all requests. When empty, the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// CABundle is the path to a PEM file with additional trusted CA
+	// certificates.
+	CABundle string `json:"ca_bundle,omitempty"`
+	// UserAgent replaces the default User-Agent header.
+	UserAgent string `json:"user_agent,omitempty"`
+	// AllowedNetworks lists networks (CIDRs or IP addresses) that feeds may
+	// be fetched from even though they are loopback, private, link-local or
+	// otherwise internal. All internal networks are blocked by default.
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+	// ContactURL is included in the default User-Agent header so feed
+	// operators can reach whoever runs the aggregator.
+	ContactURL string `json:"contact_url,omitempty"`
 }
 
 // getConfigFilePath constructs the full path to the configuration file.
@@ -32,11 +93,15 @@ func write(cfg Config) error {
 		return fmt.Errorf("Error obtaining config filepath: %w", err)
 	}
 
-	file, err := os.Create(filePath)
+	// The file holds secrets, so only the owner may read it
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("Error creating config file: %w", err)
 	}
 	defer file.Close()
+	if err := file.Chmod(0o600); err != nil {
+		return fmt.Errorf("Error setting config file permissions: %w", err)
+	}
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ") // Pretty print JSON
@@ -67,8 +132,30 @@ func Read() (Config, error) {
 	return cfg, nil
 }
 
+// SetSession updates the current_user_name and session fields and writes the updated Config back to the file.
+func (cfg *Config) SetSession(userName, token string, expiresAt time.Time) error {
+	cfg.CurrentUserName = userName
+	cfg.SessionToken = token
+	cfg.SessionExpiresAt = &expiresAt
+	return write(*cfg)
+}
+
 // SetUser updates the current_user_name field and writes the updated Config back to the file.
 func (cfg *Config) SetUser(userName string) error {
 	cfg.CurrentUserName = userName
 	return write(*cfg)
 }
+
+// ClearSession clears the current_user_name and session fields and writes the updated Config back to the file.
+func (cfg *Config) ClearSession() error {
+	cfg.CurrentUserName = ""
+	cfg.SessionToken = ""
+	cfg.SessionExpiresAt = nil
+	return write(*cfg)
+}
+
+// SetCredentialsKey updates the credentials_key field and writes the updated Config back to the file.
+func (cfg *Config) SetCredentialsKey(key string) error {
+	cfg.CredentialsKey = key
+	return write(*cfg)
+}
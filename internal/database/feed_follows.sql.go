@@ -7,11 +7,24 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countFeedFollowers = `-- name: CountFeedFollowers :one
+SELECT count(*) FROM feed_follows
+WHERE feed_id = $1
+`
+
+func (q *Queries) CountFeedFollowers(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeedFollowers, feedID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted AS (
     INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
@@ -65,7 +78,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 }
 
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds WHERE url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts FROM feeds WHERE url = $1
 `
 
 func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
@@ -79,6 +92,32 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.RetentionMaxAgeDays,
+		&i.RetentionMaxPosts,
+	)
+	return i, err
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id, folder_id, alias FROM feed_follows WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.FolderID,
+		&i.Alias,
 	)
 	return i, err
 }
@@ -88,25 +127,46 @@ SELECT
     feed_follows.id, 
     feed_follows.created_at, 
     feed_follows.updated_at, 
-    feeds.name AS feed_name, 
-    users.name AS user_name
+    coalesce(feed_follows.alias, feeds.name) AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    folders.name AS folder_name,
+    (
+        SELECT count(*)
+        FROM posts p
+        WHERE p.feed_id = feeds.id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_reads pr
+                WHERE pr.post_id = p.id AND pr.user_id = feed_follows.user_id
+            )
+    ) AS unread_count
 FROM feed_follows
 JOIN feeds ON feed_follows.feed_id = feeds.id
 JOIN users ON feed_follows.user_id = users.id
+LEFT JOIN folders ON feed_follows.folder_id = folders.id
 WHERE feed_follows.user_id = $1
-ORDER BY feeds.name
+    AND ($2::text IS NULL OR folders.name = $2)
+ORDER BY folders.name NULLS FIRST, feed_name
 `
 
+type GetFeedFollowsForUserParams struct {
+	UserID uuid.UUID
+	Folder sql.NullString
+}
+
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	FeedName  string
-	UserName  string
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FeedName    string
+	FeedUrl     string
+	UserName    string
+	FolderName  sql.NullString
+	UnreadCount int64
 }
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, arg.UserID, arg.Folder)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +179,10 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserName,
+			&i.FolderName,
+			&i.UnreadCount,
 		); err != nil {
 			return nil, err
 		}
@@ -133,3 +196,30 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const setFeedFollowAlias = `-- name: SetFeedFollowAlias :execrows
+UPDATE feed_follows
+SET alias = $1, updated_at = $2
+WHERE user_id = $3
+    AND feed_id = (SELECT id FROM feeds WHERE url = $4)
+`
+
+type SetFeedFollowAliasParams struct {
+	Alias     sql.NullString
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedUrl   string
+}
+
+func (q *Queries) SetFeedFollowAlias(ctx context.Context, arg SetFeedFollowAliasParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowAlias,
+		arg.Alias,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedUrl,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
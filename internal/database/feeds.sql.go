@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,7 +16,7 @@ import (
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts
 `
 
 type CreateFeedParams struct {
@@ -45,17 +46,32 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.RetentionMaxAgeDays,
+		&i.RetentionMaxPosts,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const getAllFeedsWithUsers = `-- name: GetAllFeedsWithUsers :many
 SELECT
     feeds.name AS feed_name,
     feeds.url AS feed_url,
-    users.name AS user_name
+    users.name AS user_name,
+    ff.alias
 FROM feeds
 JOIN users ON feeds.user_id = users.id
+LEFT JOIN feed_follows ff ON ff.feed_id = feeds.id
+    AND ff.user_id = (SELECT u.id FROM users u WHERE u.name = $1)
 ORDER BY users.name, feeds.name
 `
 
@@ -63,10 +79,11 @@ type GetAllFeedsWithUsersRow struct {
 	FeedName string
 	FeedUrl  string
 	UserName string
+	Alias    sql.NullString
 }
 
-func (q *Queries) GetAllFeedsWithUsers(ctx context.Context) ([]GetAllFeedsWithUsersRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAllFeedsWithUsers)
+func (q *Queries) GetAllFeedsWithUsers(ctx context.Context, currentUserName string) ([]GetAllFeedsWithUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeedsWithUsers, currentUserName)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +91,12 @@ func (q *Queries) GetAllFeedsWithUsers(ctx context.Context) ([]GetAllFeedsWithUs
 	var items []GetAllFeedsWithUsersRow
 	for rows.Next() {
 		var i GetAllFeedsWithUsersRow
-		if err := rows.Scan(&i.FeedName, &i.FeedUrl, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+			&i.Alias,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -87,3 +109,142 @@ func (q *Queries) GetAllFeedsWithUsers(ctx context.Context) ([]GetAllFeedsWithUs
 	}
 	return items, nil
 }
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.RetentionMaxAgeDays,
+		&i.RetentionMaxPosts,
+	)
+	return i, err
+}
+
+const getFeeds = `-- name: GetFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts FROM feeds ORDER BY name
+`
+
+func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.RetentionMaxAgeDays,
+			&i.RetentionMaxPosts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_days = $1, retention_max_posts = $2, updated_at = $3
+WHERE id = $4
+`
+
+type SetFeedRetentionParams struct {
+	RetentionMaxAgeDays sql.NullInt32
+	RetentionMaxPosts   sql.NullInt32
+	UpdatedAt           time.Time
+	ID                  uuid.UUID
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRetention,
+		arg.RetentionMaxAgeDays,
+		arg.RetentionMaxPosts,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const transferFeeds = `-- name: TransferFeeds :execrows
+UPDATE feeds
+SET user_id = (
+    SELECT ff.user_id FROM feed_follows ff
+    WHERE ff.feed_id = feeds.id AND ff.user_id <> $1
+    ORDER BY ff.created_at, ff.id
+    LIMIT 1
+), updated_at = $2
+WHERE feeds.user_id = $1
+    AND EXISTS (
+        SELECT 1 FROM feed_follows ff
+        WHERE ff.feed_id = feeds.id AND ff.user_id <> $1
+    )
+`
+
+type TransferFeedsParams struct {
+	UserID    uuid.UUID
+	UpdatedAt time.Time
+}
+
+// Gives each feed added by a user to the other user who has followed it the
+// longest. Feeds that no one else follows are left unchanged.
+func (q *Queries) TransferFeeds(ctx context.Context, arg TransferFeedsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, transferFeeds, arg.UserID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeed = `-- name: UpdateFeed :exec
+UPDATE feeds
+SET name = $1,
+    last_fetched_at = CASE WHEN url = $2 THEN last_fetched_at ELSE NULL END,
+    url = $2,
+    updated_at = $3
+WHERE id = $4
+`
+
+type UpdateFeedParams struct {
+	Name      string
+	Url       string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Changing the URL also clears last_fetched_at, so the feed is fetched from
+// its new URL next.
+func (q *Queries) UpdateFeed(ctx context.Context, arg UpdateFeedParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeed,
+		arg.Name,
+		arg.Url,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
@@ -14,7 +14,7 @@ import (
 )
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts
 FROM feeds
 WHERE last_fetched_at IS NULL
    OR last_fetched_at = (
@@ -35,6 +35,8 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.RetentionMaxAgeDays,
+		&i.RetentionMaxPosts,
 	)
 	return i, err
 }
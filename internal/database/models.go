@@ -12,13 +12,22 @@ import (
 )
 
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	UserID              uuid.UUID
+	LastFetchedAt       sql.NullTime
+	RetentionMaxAgeDays sql.NullInt32
+	RetentionMaxPosts   sql.NullInt32
+}
+
+type FeedCredential struct {
+	FeedID     uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Ciphertext []byte
 }
 
 type FeedFollow struct {
@@ -27,6 +36,16 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	FolderID  uuid.NullUUID
+	Alias     sql.NullString
+}
+
+type Folder struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
 }
 
 type Post struct {
@@ -38,11 +57,59 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Content     sql.NullString
+	Search      interface{}
 }
 
-type User struct {
-	ID        uuid.UUID
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Name      string
+	Note      sql.NullString
+}
+
+type PostStarTag struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Tag    string
+}
+
+type PrunedPost struct {
+	Url      string
+	FeedID   uuid.UUID
+	PrunedAt time.Time
+}
+
+type Session struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	IsAdmin      bool
+}
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	Secret         []byte
+	LeaseExpiresAt sql.NullTime
 }
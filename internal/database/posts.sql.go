@@ -11,8 +11,28 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPostsOfOtherFeeds = `-- name: CountPostsOfOtherFeeds :one
+SELECT count(*) FROM posts
+WHERE url = ANY($1::text[]) AND feed_id <> $2::uuid
+`
+
+type CountPostsOfOtherFeedsParams struct {
+	Urls   []string
+	FeedID uuid.UUID
+}
+
+// Posts with one of the URLs that were saved from another feed. UpsertPosts
+// leaves them alone.
+func (q *Queries) CountPostsOfOtherFeeds(ctx context.Context, arg CountPostsOfOtherFeedsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsOfOtherFeeds, pq.Array(arg.Urls), arg.FeedID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :exec
 INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -43,19 +63,88 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
 	return err
 }
 
+const deleteUnstarredPosts = `-- name: DeleteUnstarredPosts :exec
+DELETE FROM posts WHERE id NOT IN (SELECT post_id FROM post_stars)
+`
+
+// Deletes all posts that nobody starred.
+func (q *Queries) DeleteUnstarredPosts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteUnstarredPosts)
+	return err
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, title, url, feed_id FROM posts WHERE id = $1
+`
+
+type GetPostByIDRow struct {
+	ID     uuid.UUID
+	Title  string
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (GetPostByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i GetPostByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Url,
+		&i.FeedID,
+	)
+	return i, err
+}
+
+const getPostByUrl = `-- name: GetPostByUrl :one
+SELECT id, title, url, feed_id FROM posts WHERE url = $1
+`
+
+type GetPostByUrlRow struct {
+	ID     uuid.UUID
+	Title  string
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetPostByUrl(ctx context.Context, url string) (GetPostByUrlRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByUrl, url)
+	var i GetPostByUrlRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Url,
+		&i.FeedID,
+	)
+	return i, err
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT p.id, p.title, p.url, p.description, p.published_at, p.feed_id
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    p.feed_id,
+    pr.post_id IS NOT NULL AS read
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = ff.user_id
+LEFT JOIN folders fo ON fo.id = ff.folder_id
 WHERE ff.user_id = $1
+    AND (NOT $2::bool OR pr.post_id IS NULL)
+    AND ($3::text IS NULL OR fo.name = $3)
 ORDER BY p.published_at DESC
-LIMIT $2
+LIMIT $4
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID     uuid.UUID
+	UnreadOnly bool
+	Folder     sql.NullString
+	Limit      int32
 }
 
 type GetPostsForUserRow struct {
@@ -65,10 +154,16 @@ type GetPostsForUserRow struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Read        bool
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Folder,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +178,94 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Read,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    p.feed_id,
+    coalesce(ff.alias, f.name) AS feed_name,
+    ts_rank_cd(p.search, q.query)::float8 AS rank,
+    ts_headline(
+        'english',
+        coalesce(p.description, '') || ' ' || coalesce(p.content, ''),
+        q.query,
+        'StartSel=**, StopSel=**, MaxWords=30, MinWords=10, MaxFragments=2'
+    ) AS snippet
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+CROSS JOIN websearch_to_tsquery('english', $1) AS q(query)
+WHERE ff.user_id = $2
+    AND p.search @@ q.query
+    AND ($3::text IS NULL OR f.url = $3)
+    AND ($4::timestamp IS NULL OR p.published_at >= $4)
+    AND ($5::timestamp IS NULL OR p.published_at < $5)
+ORDER BY rank DESC, p.published_at DESC NULLS LAST
+LIMIT $6
+`
+
+type SearchPostsForUserParams struct {
+	Query      string
+	UserID     uuid.UUID
+	FeedUrl    sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+	MaxResults int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	FeedName    string
+	Rank        float64
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Since,
+		arg.Until,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -96,3 +279,79 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const upsertPosts = `-- name: UpsertPosts :many
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
+SELECT
+    t.id,
+    $1::timestamp,
+    $1::timestamp,
+    t.title,
+    t.url,
+    NULLIF(t.description, ''),
+    NULLIF(t.published_at, '0001-01-01 00:00:00'::timestamp),
+    $2::uuid,
+    NULLIF(t.content, '')
+FROM unnest(
+    $3::uuid[],
+    $4::text[],
+    $5::text[],
+    $6::text[],
+    $7::timestamp[],
+    $8::text[]
+) AS t(id, title, url, description, published_at, content)
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = $2::uuid AND pp.url = t.url)
+ON CONFLICT (url) DO UPDATE
+SET title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    content = EXCLUDED.content,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.feed_id = EXCLUDED.feed_id
+    AND (posts.title, posts.description, posts.published_at, posts.content)
+    IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.description, EXCLUDED.published_at, EXCLUDED.content)
+RETURNING (xmax = 0) AS inserted
+`
+
+type UpsertPostsParams struct {
+	Now          time.Time
+	FeedID       uuid.UUID
+	Ids          []uuid.UUID
+	Titles       []string
+	Urls         []string
+	Descriptions []string
+	PublishedAts []time.Time
+	Contents     []string
+}
+
+func (q *Queries) UpsertPosts(ctx context.Context, arg UpsertPostsParams) ([]bool, error) {
+	rows, err := q.db.QueryContext(ctx, upsertPosts,
+		arg.Now,
+		arg.FeedID,
+		pq.Array(arg.Ids),
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.Contents),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []bool
+	for rows.Next() {
+		var inserted bool
+		if err := rows.Scan(&inserted); err != nil {
+			return nil, err
+		}
+		items = append(items, inserted)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,1657 @@
+// Package memstore is an in-memory implementation of the queries in
+// internal/database. It follows the semantics of the SQL queries closely
+// enough to run command handlers in tests without a database: missing rows
+// are reported as sql.ErrNoRows, unique constraints as ErrUniqueViolation
+// and missing references as ErrForeignKeyViolation, and deletes cascade like
+// the schema's ON DELETE clauses.
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/search"
+	"github.com/google/uuid"
+)
+
+// ErrUniqueViolation is returned when a write would break a unique constraint.
+var ErrUniqueViolation = errors.New("memstore: unique constraint violated")
+
+// ErrForeignKeyViolation is returned when a write references a missing row.
+var ErrForeignKeyViolation = errors.New("memstore: foreign key constraint violated")
+
+// Store holds all tables in memory. It is safe for concurrent use.
+type Store struct {
+	mu sync.Mutex
+	// txMu serializes transactions, so a rollback cannot undo the writes of
+	// another transaction.
+	txMu   sync.Mutex
+	tables tables
+}
+
+// tables holds the rows of each table by primary key.
+type tables struct {
+	users       map[uuid.UUID]database.User
+	feeds       map[uuid.UUID]database.Feed
+	feedFollows map[uuid.UUID]database.FeedFollow
+	folders     map[uuid.UUID]database.Folder
+	posts       map[uuid.UUID]database.Post
+	postReads   map[userPostKey]database.PostRead
+	postStars   map[userPostKey]database.PostStar
+	starTags    map[database.PostStarTag]bool
+	prunedPosts map[prunedPostKey]database.PrunedPost
+	sessions    map[string]database.Session
+	websubs     map[uuid.UUID]database.WebsubSubscription
+	credentials map[uuid.UUID]database.FeedCredential
+}
+
+// prunedPostKey is the primary key of pruned_posts.
+type prunedPostKey struct {
+	feedID uuid.UUID
+	url    string
+}
+
+// userPostKey is the primary key of post_reads and post_stars.
+type userPostKey struct {
+	userID uuid.UUID
+	postID uuid.UUID
+}
+
+// clone returns a copy of the tables that can be restored on rollback.
+func (t tables) clone() tables {
+	return tables{
+		users:       maps.Clone(t.users),
+		feeds:       maps.Clone(t.feeds),
+		feedFollows: maps.Clone(t.feedFollows),
+		folders:     maps.Clone(t.folders),
+		posts:       maps.Clone(t.posts),
+		postReads:   maps.Clone(t.postReads),
+		postStars:   maps.Clone(t.postStars),
+		starTags:    maps.Clone(t.starTags),
+		prunedPosts: maps.Clone(t.prunedPosts),
+		sessions:    maps.Clone(t.sessions),
+		websubs:     maps.Clone(t.websubs),
+		credentials: maps.Clone(t.credentials),
+	}
+}
+
+var _ database.Querier = (*Store)(nil)
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{tables: tables{
+		users:       make(map[uuid.UUID]database.User),
+		feeds:       make(map[uuid.UUID]database.Feed),
+		feedFollows: make(map[uuid.UUID]database.FeedFollow),
+		folders:     make(map[uuid.UUID]database.Folder),
+		posts:       make(map[uuid.UUID]database.Post),
+		postReads:   make(map[userPostKey]database.PostRead),
+		postStars:   make(map[userPostKey]database.PostStar),
+		starTags:    make(map[database.PostStarTag]bool),
+		prunedPosts: make(map[prunedPostKey]database.PrunedPost),
+		sessions:    make(map[string]database.Session),
+		websubs:     make(map[uuid.UUID]database.WebsubSubscription),
+		credentials: make(map[uuid.UUID]database.FeedCredential),
+	}}
+}
+
+// InTx runs fn against the store and restores the previous contents if fn
+// returns an error. Transactions run one at a time, but writes made outside
+// a transaction are not isolated from them.
+func (s *Store) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.tables.clone()
+	s.mu.Unlock()
+
+	if err := fn(s); err != nil {
+		s.mu.Lock()
+		s.tables = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// lock locks the store and returns its tables.
+func (s *Store) lock() *tables {
+	s.mu.Lock()
+	return &s.tables
+}
+
+// AddPostStarTag tags a starred post.
+func (s *Store) AddPostStarTag(ctx context.Context, arg database.AddPostStarTagParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.postStars[userPostKey{arg.UserID, arg.PostID}]; !ok {
+		return fmt.Errorf("%w: post star %s", ErrForeignKeyViolation, arg.PostID)
+	}
+	t.starTags[database.PostStarTag(arg)] = true
+	return nil
+}
+
+// CountAdmins counts the users who are admins.
+func (s *Store) CountAdmins(ctx context.Context) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, user := range t.users {
+		if user.IsAdmin {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountFeedFollowers counts the users who follow a feed.
+func (s *Store) CountFeedFollowers(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, follow := range t.feedFollows {
+		if follow.FeedID == feedID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountPostsOfOtherFeeds counts the posts with one of the URLs that were
+// saved from another feed.
+func (s *Store) CountPostsOfOtherFeeds(ctx context.Context, arg database.CountPostsOfOtherFeedsParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, postURL := range arg.Urls {
+		if post, ok := t.postByURL(postURL); ok && post.FeedID != arg.FeedID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CreateFeed inserts a feed.
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return database.Feed{}, fmt.Errorf("%w: user %s", ErrForeignKeyViolation, arg.UserID)
+	}
+	if _, ok := t.feeds[arg.ID]; ok {
+		return database.Feed{}, fmt.Errorf("%w: feed id %s", ErrUniqueViolation, arg.ID)
+	}
+	if _, ok := t.feedByURL(arg.Url); ok {
+		return database.Feed{}, fmt.Errorf("%w: feed url %s", ErrUniqueViolation, arg.Url)
+	}
+
+	feed := database.Feed{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+	}
+	t.feeds[feed.ID] = feed
+	return feed, nil
+}
+
+// CreateFeedFollow inserts a follow and returns it with the user and feed
+// names.
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	user, ok := t.users[arg.UserID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, fmt.Errorf("%w: user %s", ErrForeignKeyViolation, arg.UserID)
+	}
+	feed, ok := t.feeds[arg.FeedID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, fmt.Errorf("%w: feed %s", ErrForeignKeyViolation, arg.FeedID)
+	}
+	if _, ok := t.feedFollows[arg.ID]; ok {
+		return database.CreateFeedFollowRow{}, fmt.Errorf("%w: feed follow id %s", ErrUniqueViolation, arg.ID)
+	}
+	for _, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			return database.CreateFeedFollowRow{}, fmt.Errorf("%w: %s already follows %s", ErrUniqueViolation, user.Name, feed.Url)
+		}
+	}
+
+	t.feedFollows[arg.ID] = database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	}
+	return database.CreateFeedFollowRow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserName:  user.Name,
+		FeedName:  feed.Name,
+	}, nil
+}
+
+// CreateFolder inserts a folder.
+func (s *Store) CreateFolder(ctx context.Context, arg database.CreateFolderParams) (database.Folder, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return database.Folder{}, fmt.Errorf("%w: user %s", ErrForeignKeyViolation, arg.UserID)
+	}
+	if _, ok := t.folders[arg.ID]; ok {
+		return database.Folder{}, fmt.Errorf("%w: folder id %s", ErrUniqueViolation, arg.ID)
+	}
+	if _, ok := t.folderByName(arg.UserID, arg.Name); ok {
+		return database.Folder{}, fmt.Errorf("%w: folder %s", ErrUniqueViolation, arg.Name)
+	}
+
+	folder := database.Folder(arg)
+	t.folders[folder.ID] = folder
+	return folder, nil
+}
+
+// CreatePost inserts a post.
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.feeds[arg.FeedID]; !ok {
+		return fmt.Errorf("%w: feed %s", ErrForeignKeyViolation, arg.FeedID)
+	}
+	if _, ok := t.posts[arg.ID]; ok {
+		return fmt.Errorf("%w: post id %s", ErrUniqueViolation, arg.ID)
+	}
+	if _, ok := t.postByURL(arg.Url); ok {
+		return fmt.Errorf("%w: post url %s", ErrUniqueViolation, arg.Url)
+	}
+
+	t.posts[arg.ID] = database.Post{
+		ID:          arg.ID,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: arg.PublishedAt,
+		FeedID:      arg.FeedID,
+	}
+	return nil
+}
+
+// CreateSession inserts a session.
+func (s *Store) CreateSession(ctx context.Context, arg database.CreateSessionParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return fmt.Errorf("%w: user %s", ErrForeignKeyViolation, arg.UserID)
+	}
+	if _, ok := t.sessions[arg.TokenHash]; ok {
+		return fmt.Errorf("%w: session", ErrUniqueViolation)
+	}
+	t.sessions[arg.TokenHash] = database.Session(arg)
+	return nil
+}
+
+// CreateUser inserts a user.
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[arg.ID]; ok {
+		return database.User{}, fmt.Errorf("%w: user id %s", ErrUniqueViolation, arg.ID)
+	}
+	if _, ok := t.userByName(arg.Name); ok {
+		return database.User{}, fmt.Errorf("%w: user name %s", ErrUniqueViolation, arg.Name)
+	}
+
+	user := database.User(arg)
+	t.users[user.ID] = user
+	return user, nil
+}
+
+// DeleteAllUsers deletes all users and, through them, all other rows.
+func (s *Store) DeleteAllUsers(ctx context.Context) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for id := range t.users {
+		t.deleteUser(id)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions that expired at or before the
+// given time.
+func (s *Store) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range t.sessions {
+		if !session.ExpiresAt.After(expiresAt) {
+			delete(t.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// DeleteFeed deletes a feed with the rows that reference it.
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.feeds[id]; ok {
+		t.deleteFeed(id)
+	}
+	return nil
+}
+
+// DeleteFeedCredentials deletes the credentials of a feed.
+func (s *Store) DeleteFeedCredentials(ctx context.Context, feedID uuid.UUID) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	delete(t.credentials, feedID)
+	return nil
+}
+
+// DeleteFeedFollowByUserAndURL deletes a user's follow of the feed with the
+// given URL.
+func (s *Store) DeleteFeedFollowByUserAndURL(ctx context.Context, arg database.DeleteFeedFollowByUserAndURLParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feedByURL(arg.Url)
+	if !ok {
+		return nil
+	}
+	for id, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID && follow.FeedID == feed.ID {
+			delete(t.feedFollows, id)
+		}
+	}
+	return nil
+}
+
+// DeleteFolder deletes a user's folder by name. The feeds in it are kept
+// but no longer in a folder.
+func (s *Store) DeleteFolder(ctx context.Context, arg database.DeleteFolderParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	folder, ok := t.folderByName(arg.UserID, arg.Name)
+	if !ok {
+		return 0, nil
+	}
+	t.deleteFolder(folder.ID)
+	return 1, nil
+}
+
+// DeletePosts deletes posts by ID and returns the number deleted.
+func (s *Store) DeletePosts(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		if _, ok := t.posts[id]; ok {
+			t.deletePost(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DeletePostStarTag removes a tag from a starred post.
+func (s *Store) DeletePostStarTag(ctx context.Context, arg database.DeletePostStarTagParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	delete(t.starTags, database.PostStarTag(arg))
+	return nil
+}
+
+// DeleteSession deletes a session.
+func (s *Store) DeleteSession(ctx context.Context, tokenHash string) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	delete(t.sessions, tokenHash)
+	return nil
+}
+
+// DeleteUnstarredPosts deletes all posts that nobody starred, with their read
+// states.
+func (s *Store) DeleteUnstarredPosts(ctx context.Context) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	starred := make(map[uuid.UUID]bool)
+	for key := range t.postStars {
+		starred[key.postID] = true
+	}
+	for id := range t.posts {
+		if !starred[id] {
+			t.deletePost(id)
+		}
+	}
+	return nil
+}
+
+// DeleteUser deletes a user with the rows that reference it.
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[id]; ok {
+		t.deleteUser(id)
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes the sessions of a user except the one with the
+// given token hash.
+func (s *Store) DeleteUserSessions(ctx context.Context, arg database.DeleteUserSessionsParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range t.sessions {
+		if session.UserID == arg.UserID && hash != arg.KeepTokenHash {
+			delete(t.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// DeleteWebSubSubscription deletes a WebSub subscription.
+func (s *Store) DeleteWebSubSubscription(ctx context.Context, id uuid.UUID) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	delete(t.websubs, id)
+	return nil
+}
+
+// ForgetPrunedPosts forgets all pruned posts, so fetching their feeds saves
+// them again.
+func (s *Store) ForgetPrunedPosts(ctx context.Context) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	clear(t.prunedPosts)
+	return nil
+}
+
+// GetAllFeedsWithUsers lists all feeds with the names of the users who added
+// them, ordered by user name and feed name, and the alias the current user
+// gave each feed they follow.
+func (s *Store) GetAllFeedsWithUsers(ctx context.Context, currentUserName string) ([]database.GetAllFeedsWithUsersRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	current, _ := t.userByName(currentUserName)
+	var rows []database.GetAllFeedsWithUsersRow
+	for _, feed := range t.feeds {
+		var alias sql.NullString
+		if follow, ok := t.followByURL(current.ID, feed.Url); ok {
+			alias = follow.Alias
+		}
+		rows = append(rows, database.GetAllFeedsWithUsersRow{
+			FeedName: feed.Name,
+			FeedUrl:  feed.Url,
+			UserName: t.users[feed.UserID].Name,
+			Alias:    alias,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetAllFeedsWithUsersRow) int {
+		return cmp.Or(cmp.Compare(a.UserName, b.UserName), cmp.Compare(a.FeedName, b.FeedName))
+	})
+	return rows, nil
+}
+
+// GetFeedByID returns the feed with the given ID.
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feeds[id]
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+// GetFeedByUrl returns the feed with the given URL.
+func (s *Store) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feedByURL(url)
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+// GetFeedCredentials returns the credentials of a feed.
+func (s *Store) GetFeedCredentials(ctx context.Context, feedID uuid.UUID) (database.FeedCredential, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	creds, ok := t.credentials[feedID]
+	if !ok {
+		return database.FeedCredential{}, sql.ErrNoRows
+	}
+	return creds, nil
+}
+
+// GetFeedFollow returns a user's follow of a feed.
+func (s *Store) GetFeedFollow(ctx context.Context, arg database.GetFeedFollowParams) (database.FeedFollow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for _, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			return follow, nil
+		}
+	}
+	return database.FeedFollow{}, sql.ErrNoRows
+}
+
+// GetFeedFollowsForUser lists a user's follows, optionally only those in a
+// folder. Follows outside a folder come first, then follows by folder name,
+// each ordered by feed name.
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, arg database.GetFeedFollowsForUserParams) ([]database.GetFeedFollowsForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedFollowsForUserRow
+	for _, follow := range t.feedFollows {
+		folderName := t.folderName(follow)
+		if follow.UserID != arg.UserID || (arg.Folder.Valid && folderName != arg.Folder) {
+			continue
+		}
+		rows = append(rows, database.GetFeedFollowsForUserRow{
+			ID:          follow.ID,
+			CreatedAt:   follow.CreatedAt,
+			UpdatedAt:   follow.UpdatedAt,
+			FeedName:    t.followName(follow),
+			FeedUrl:     t.feeds[follow.FeedID].Url,
+			UserName:    t.users[follow.UserID].Name,
+			FolderName:  folderName,
+			UnreadCount: t.unreadCount(follow.UserID, follow.FeedID),
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetFeedFollowsForUserRow) int {
+		if a.FolderName.Valid != b.FolderName.Valid {
+			if !a.FolderName.Valid {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(a.FolderName.String, b.FolderName.String),
+			cmp.Compare(a.FeedName, b.FeedName),
+		)
+	})
+	return rows, nil
+}
+
+// GetFolderByName returns a user's folder by name.
+func (s *Store) GetFolderByName(ctx context.Context, arg database.GetFolderByNameParams) (database.Folder, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	folder, ok := t.folderByName(arg.UserID, arg.Name)
+	if !ok {
+		return database.Folder{}, sql.ErrNoRows
+	}
+	return folder, nil
+}
+
+// GetFoldersForUser lists a user's folders by name, with the number of feeds
+// in each and the number of posts in them that the user has not read.
+func (s *Store) GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFoldersForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFoldersForUserRow
+	for _, folder := range t.folders {
+		if folder.UserID != userID {
+			continue
+		}
+		row := database.GetFoldersForUserRow{ID: folder.ID, Name: folder.Name}
+		for _, follow := range t.feedFollows {
+			if follow.FolderID.Valid && follow.FolderID.UUID == folder.ID {
+				row.FeedCount++
+				row.UnreadCount += t.unreadCount(userID, follow.FeedID)
+			}
+		}
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b database.GetFoldersForUserRow) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rows, nil
+}
+
+// GetFeeds lists all feeds, ordered by name.
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feeds := slices.Collect(maps.Values(t.feeds))
+	slices.SortFunc(feeds, func(a, b database.Feed) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return feeds, nil
+}
+
+// GetNextFeedToFetch returns a feed that was never fetched, or else the one
+// fetched longest ago.
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var next database.Feed
+	found := false
+	for _, feed := range t.feeds {
+		if !found || fetchedBefore(feed, next) {
+			next, found = feed, true
+		}
+	}
+	if !found {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return next, nil
+}
+
+// fetchedBefore reports whether feed a is due before feed b, with feeds that
+// were never fetched first and ties broken by ID for a stable order.
+func fetchedBefore(a, b database.Feed) bool {
+	if a.LastFetchedAt.Valid != b.LastFetchedAt.Valid {
+		return !a.LastFetchedAt.Valid
+	}
+	if a.LastFetchedAt.Valid && !a.LastFetchedAt.Time.Equal(b.LastFetchedAt.Time) {
+		return a.LastFetchedAt.Time.Before(b.LastFetchedAt.Time)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// GetPostByID returns the post with the given ID.
+func (s *Store) GetPostByID(ctx context.Context, id uuid.UUID) (database.GetPostByIDRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	post, ok := t.posts[id]
+	if !ok {
+		return database.GetPostByIDRow{}, sql.ErrNoRows
+	}
+	return database.GetPostByIDRow{ID: post.ID, Title: post.Title, Url: post.Url, FeedID: post.FeedID}, nil
+}
+
+// GetPostByUrl returns the post with the given URL.
+func (s *Store) GetPostByUrl(ctx context.Context, url string) (database.GetPostByUrlRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	post, ok := t.postByURL(url)
+	if !ok {
+		return database.GetPostByUrlRow{}, sql.ErrNoRows
+	}
+	return database.GetPostByUrlRow{ID: post.ID, Title: post.Title, Url: post.Url, FeedID: post.FeedID}, nil
+}
+
+// GetPostsForUser lists the posts of the feeds a user follows, newest first
+// with undated posts before all others, optionally leaving out posts the user
+// has read or that are not in a folder.
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	followed := make(map[uuid.UUID]bool)
+	for _, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID && (!arg.Folder.Valid || t.folderName(follow) == arg.Folder) {
+			followed[follow.FeedID] = true
+		}
+	}
+
+	var rows []database.GetPostsForUserRow
+	for _, post := range t.posts {
+		_, read := t.postReads[userPostKey{arg.UserID, post.ID}]
+		if !followed[post.FeedID] || (arg.UnreadOnly && read) {
+			continue
+		}
+		rows = append(rows, database.GetPostsForUserRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Read:        read,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetPostsForUserRow) int {
+		return comparePublishedDesc(a.PublishedAt, b.PublishedAt)
+	})
+
+	if int(arg.Limit) < len(rows) {
+		rows = rows[:max(arg.Limit, 0)]
+	}
+	return rows, nil
+}
+
+// comparePublishedDesc orders publication times like ORDER BY published_at
+// DESC in PostgreSQL: NULL first, then newest to oldest.
+func comparePublishedDesc(a, b sql.NullTime) int {
+	if a.Valid != b.Valid {
+		if !a.Valid {
+			return -1
+		}
+		return 1
+	}
+	return b.Time.Compare(a.Time)
+}
+
+// GetPostsToPrune lists the posts of a feed that are older than a time or
+// beyond a number of most recent posts, oldest first. Posts are dated by
+// publication, or by when they were first saved if undated. Starred posts
+// are never listed, but count towards the number of posts kept.
+func (s *Store) GetPostsToPrune(ctx context.Context, arg database.GetPostsToPruneParams) ([]uuid.UUID, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var posts []database.Post
+	for _, post := range t.posts {
+		if post.FeedID == arg.FeedID {
+			posts = append(posts, post)
+		}
+	}
+	slices.SortFunc(posts, func(a, b database.Post) int {
+		return cmp.Or(postDate(b).Compare(postDate(a)), cmp.Compare(a.ID.String(), b.ID.String()))
+	})
+
+	starred := make(map[uuid.UUID]bool)
+	for key := range t.postStars {
+		starred[key.postID] = true
+	}
+
+	var ids []uuid.UUID
+	for i := len(posts) - 1; i >= 0; i-- {
+		post := posts[i]
+		tooOld := arg.Before.Valid && postDate(post).Before(arg.Before.Time)
+		tooMany := arg.Keep.Valid && i+1 > int(arg.Keep.Int32)
+		if !starred[post.ID] && (tooOld || tooMany) {
+			ids = append(ids, post.ID)
+		}
+	}
+	return ids, nil
+}
+
+// postDate returns the time a post is dated by when pruning.
+func postDate(post database.Post) time.Time {
+	if post.PublishedAt.Valid {
+		return post.PublishedAt.Time
+	}
+	return post.CreatedAt
+}
+
+// GetSessionUser returns the user of a session that has not expired yet.
+func (s *Store) GetSessionUser(ctx context.Context, arg database.GetSessionUserParams) (database.User, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	session, ok := t.sessions[arg.TokenHash]
+	if !ok || !session.ExpiresAt.After(arg.Now) {
+		return database.User{}, sql.ErrNoRows
+	}
+	user, ok := t.users[session.UserID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// GetStarTagsForUser lists the tags of a user's starred posts, ordered by
+// post and tag.
+func (s *Store) GetStarTagsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetStarTagsForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetStarTagsForUserRow
+	for tag := range t.starTags {
+		if tag.UserID == userID {
+			rows = append(rows, database.GetStarTagsForUserRow{PostID: tag.PostID, Tag: tag.Tag})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.GetStarTagsForUserRow) int {
+		return cmp.Or(cmp.Compare(a.PostID.String(), b.PostID.String()), cmp.Compare(a.Tag, b.Tag))
+	})
+	return rows, nil
+}
+
+// GetStarredPostsForUser lists a user's starred posts, most recently starred
+// first, optionally only those with a tag.
+func (s *Store) GetStarredPostsForUser(ctx context.Context, arg database.GetStarredPostsForUserParams) ([]database.GetStarredPostsForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetStarredPostsForUserRow
+	for key, star := range t.postStars {
+		if key.userID != arg.UserID {
+			continue
+		}
+		if arg.Tag.Valid && !t.starTags[database.PostStarTag{UserID: key.userID, PostID: key.postID, Tag: arg.Tag.String}] {
+			continue
+		}
+		post := t.posts[key.postID]
+		rows = append(rows, database.GetStarredPostsForUserRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedName:    t.feedName(arg.UserID, post.FeedID),
+			Note:        star.Note,
+			StarredAt:   star.CreatedAt,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetStarredPostsForUserRow) int {
+		return b.StarredAt.Compare(a.StarredAt)
+	})
+	return rows, nil
+}
+
+// GetUser returns the user with the given name.
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	user, ok := t.userByName(name)
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// GetUsers lists all users ordered by name.
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	users := slices.Collect(maps.Values(t.users))
+	slices.SortFunc(users, func(a, b database.User) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return users, nil
+}
+
+// GetWebSubSubscription returns the WebSub subscription with the given ID.
+func (s *Store) GetWebSubSubscription(ctx context.Context, id uuid.UUID) (database.WebsubSubscription, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	sub, ok := t.websubs[id]
+	if !ok {
+		return database.WebsubSubscription{}, sql.ErrNoRows
+	}
+	return sub, nil
+}
+
+// GetWebSubSubscriptionByFeedID returns the WebSub subscription of a feed.
+func (s *Store) GetWebSubSubscriptionByFeedID(ctx context.Context, feedID uuid.UUID) (database.WebsubSubscription, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range t.websubs {
+		if sub.FeedID == feedID {
+			return sub, nil
+		}
+	}
+	return database.WebsubSubscription{}, sql.ErrNoRows
+}
+
+// GetWebSubSubscriptionsToRenew lists the subscriptions whose lease expires
+// before the given time, soonest first.
+func (s *Store) GetWebSubSubscriptionsToRenew(ctx context.Context, leaseExpiresAt sql.NullTime) ([]database.WebsubSubscription, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var subs []database.WebsubSubscription
+	if !leaseExpiresAt.Valid {
+		return subs, nil
+	}
+	for _, sub := range t.websubs {
+		if sub.LeaseExpiresAt.Valid && sub.LeaseExpiresAt.Time.Before(leaseExpiresAt.Time) {
+			subs = append(subs, sub)
+		}
+	}
+	slices.SortFunc(subs, func(a, b database.WebsubSubscription) int {
+		return a.LeaseExpiresAt.Time.Compare(b.LeaseExpiresAt.Time)
+	})
+	return subs, nil
+}
+
+// MarkFeedFetched sets the time a feed was last fetched.
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feeds[arg.ID]
+	if !ok {
+		return nil
+	}
+	feed.LastFetchedAt = arg.LastFetchedAt
+	feed.UpdatedAt = arg.UpdatedAt
+	t.feeds[feed.ID] = feed
+	return nil
+}
+
+// MarkPostsRead marks the posts of the feeds a user follows as read,
+// optionally only the post with a URL, the posts of a feed or the posts
+// published before a time. It returns the number of posts that were unread.
+func (s *Store) MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	followed := make(map[uuid.UUID]bool)
+	for _, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID {
+			followed[follow.FeedID] = true
+		}
+	}
+
+	var marked int64
+	for _, post := range t.posts {
+		key := userPostKey{arg.UserID, post.ID}
+		if _, read := t.postReads[key]; read || !followed[post.FeedID] ||
+			!t.matchesPostFilter(post, arg.PostUrl, arg.FeedUrl, arg.Before) {
+			continue
+		}
+		t.postReads[key] = database.PostRead{UserID: arg.UserID, PostID: post.ID, ReadAt: arg.ReadAt}
+		marked++
+	}
+	return marked, nil
+}
+
+// MarkPostsUnread marks a user's read posts as unread, optionally only the
+// post with a URL, the posts of a feed or the posts published before a time.
+// It returns the number of posts that were read.
+func (s *Store) MarkPostsUnread(ctx context.Context, arg database.MarkPostsUnreadParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var marked int64
+	for key := range t.postReads {
+		if key.userID != arg.UserID ||
+			!t.matchesPostFilter(t.posts[key.postID], arg.PostUrl, arg.FeedUrl, arg.Before) {
+			continue
+		}
+		delete(t.postReads, key)
+		marked++
+	}
+	return marked, nil
+}
+
+// RememberPrunedPosts records the URLs of posts that are about to be pruned.
+func (s *Store) RememberPrunedPosts(ctx context.Context, arg database.RememberPrunedPostsParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	for _, id := range arg.Ids {
+		post, ok := t.posts[id]
+		key := prunedPostKey{feedID: post.FeedID, url: post.Url}
+		if _, pruned := t.prunedPosts[key]; !ok || pruned {
+			continue
+		}
+		t.prunedPosts[key] = database.PrunedPost{Url: post.Url, FeedID: post.FeedID, PrunedAt: arg.PrunedAt}
+	}
+	return nil
+}
+
+// RemoveFeedFollowFromFolder takes a followed feed out of a folder.
+func (s *Store) RemoveFeedFollowFromFolder(ctx context.Context, arg database.RemoveFeedFollowFromFolderParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	follow, ok := t.followByURL(arg.UserID, arg.FeedUrl)
+	if !ok || !arg.FolderID.Valid || follow.FolderID != arg.FolderID {
+		return 0, nil
+	}
+	follow.FolderID = uuid.NullUUID{}
+	follow.UpdatedAt = arg.UpdatedAt
+	t.feedFollows[follow.ID] = follow
+	return 1, nil
+}
+
+// RenameUser changes the name of a user.
+func (s *Store) RenameUser(ctx context.Context, arg database.RenameUserParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	user, ok := t.users[arg.ID]
+	if !ok {
+		return nil
+	}
+	if other, ok := t.userByName(arg.Name); ok && other.ID != user.ID {
+		return fmt.Errorf("%w: user name %s", ErrUniqueViolation, arg.Name)
+	}
+	user.Name = arg.Name
+	user.UpdatedAt = arg.UpdatedAt
+	t.users[user.ID] = user
+	return nil
+}
+
+// SearchPostsForUser finds the posts of the feeds a user follows that match
+// a search query. Posts are ranked by the number of query words in their
+// title, description and content, weighted in that order, and the snippet is
+// the start of their description or content.
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	query := search.Parse(arg.Query)
+	followed := make(map[uuid.UUID]bool)
+	for _, follow := range t.feedFollows {
+		if follow.UserID == arg.UserID {
+			followed[follow.FeedID] = true
+		}
+	}
+
+	var rows []database.SearchPostsForUserRow
+	for _, post := range t.posts {
+		feed := t.feeds[post.FeedID]
+		if !followed[post.FeedID] ||
+			(arg.FeedUrl.Valid && feed.Url != arg.FeedUrl.String) ||
+			(arg.Since.Valid && (!post.PublishedAt.Valid || post.PublishedAt.Time.Before(arg.Since.Time))) ||
+			(arg.Until.Valid && (!post.PublishedAt.Valid || !post.PublishedAt.Time.Before(arg.Until.Time))) {
+			continue
+		}
+
+		body := strings.TrimSpace(post.Description.String + " " + post.Content.String)
+		if !query.Match(post.Title + " " + body) {
+			continue
+		}
+
+		rows = append(rows, database.SearchPostsForUserRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			FeedName:    t.feedName(arg.UserID, feed.ID),
+			Rank:        rank(query, post.Title, post.Description.String, post.Content.String),
+			Snippet:     snippet(body),
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.SearchPostsForUserRow) int {
+		// Undated posts go last among posts with the same rank
+		if a.PublishedAt.Valid != b.PublishedAt.Valid {
+			return cmp.Or(cmp.Compare(b.Rank, a.Rank), comparePublishedDesc(b.PublishedAt, a.PublishedAt))
+		}
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), comparePublishedDesc(a.PublishedAt, b.PublishedAt))
+	})
+
+	if int(arg.MaxResults) < len(rows) {
+		rows = rows[:max(arg.MaxResults, 0)]
+	}
+	return rows, nil
+}
+
+// rank counts the query words found in each field, weighting the title
+// highest.
+func rank(query search.Query, title, description, content string) float64 {
+	weights := []float64{1, 0.4, 0.1}
+	var score float64
+	for i, field := range []string{title, description, content} {
+		words := search.Words(field)
+		for _, group := range query.Groups {
+			for _, term := range group {
+				if !term.Negated && slices.Contains(words, term.Words[0]) {
+					score += weights[i]
+				}
+			}
+		}
+	}
+	return score
+}
+
+// snippet returns the start of text, cut at a word boundary.
+func snippet(text string) string {
+	words := strings.Fields(text)
+	if len(words) <= 20 {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:20], " ") + "..."
+}
+
+// SetFeedFollowAlias sets or clears the name a user gave a followed feed.
+func (s *Store) SetFeedFollowAlias(ctx context.Context, arg database.SetFeedFollowAliasParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	follow, ok := t.followByURL(arg.UserID, arg.FeedUrl)
+	if !ok {
+		return 0, nil
+	}
+	follow.Alias = arg.Alias
+	follow.UpdatedAt = arg.UpdatedAt
+	t.feedFollows[follow.ID] = follow
+	return 1, nil
+}
+
+// SetFeedFollowFolder moves a followed feed into a folder.
+func (s *Store) SetFeedFollowFolder(ctx context.Context, arg database.SetFeedFollowFolderParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	follow, ok := t.followByURL(arg.UserID, arg.FeedUrl)
+	if !ok {
+		return 0, nil
+	}
+	if _, ok := t.folders[arg.FolderID.UUID]; arg.FolderID.Valid && !ok {
+		return 0, fmt.Errorf("%w: folder %s", ErrForeignKeyViolation, arg.FolderID.UUID)
+	}
+	follow.FolderID = arg.FolderID
+	follow.UpdatedAt = arg.UpdatedAt
+	t.feedFollows[follow.ID] = follow
+	return 1, nil
+}
+
+// SetFeedRetention sets the retention settings of a feed.
+func (s *Store) SetFeedRetention(ctx context.Context, arg database.SetFeedRetentionParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feeds[arg.ID]
+	if !ok {
+		return nil
+	}
+	feed.RetentionMaxAgeDays = arg.RetentionMaxAgeDays
+	feed.RetentionMaxPosts = arg.RetentionMaxPosts
+	feed.UpdatedAt = arg.UpdatedAt
+	t.feeds[feed.ID] = feed
+	return nil
+}
+
+// SetUserAdmin grants or revokes admin rights of the user with the given
+// name.
+func (s *Store) SetUserAdmin(ctx context.Context, arg database.SetUserAdminParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	user, ok := t.userByName(arg.Name)
+	if !ok {
+		return 0, nil
+	}
+	user.IsAdmin = arg.IsAdmin
+	user.UpdatedAt = arg.UpdatedAt
+	t.users[user.ID] = user
+	return 1, nil
+}
+
+// SetUserPassword sets the password hash of a user.
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	user, ok := t.users[arg.ID]
+	if !ok {
+		return nil
+	}
+	user.PasswordHash = arg.PasswordHash
+	user.UpdatedAt = arg.UpdatedAt
+	t.users[user.ID] = user
+	return nil
+}
+
+// SetWebSubLease sets the lease expiry of a WebSub subscription.
+func (s *Store) SetWebSubLease(ctx context.Context, arg database.SetWebSubLeaseParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	sub, ok := t.websubs[arg.ID]
+	if !ok {
+		return nil
+	}
+	sub.LeaseExpiresAt = arg.LeaseExpiresAt
+	sub.UpdatedAt = arg.UpdatedAt
+	t.websubs[sub.ID] = sub
+	return nil
+}
+
+// StarPost stars a post for a user, or updates the star of a post that is
+// already starred. A null note keeps the existing note.
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return fmt.Errorf("%w: user %s", ErrForeignKeyViolation, arg.UserID)
+	}
+	if _, ok := t.posts[arg.PostID]; !ok {
+		return fmt.Errorf("%w: post %s", ErrForeignKeyViolation, arg.PostID)
+	}
+
+	key := userPostKey{arg.UserID, arg.PostID}
+	star, ok := t.postStars[key]
+	if !ok {
+		t.postStars[key] = database.PostStar(arg)
+		return nil
+	}
+	star.UpdatedAt = arg.UpdatedAt
+	if arg.Note.Valid {
+		star.Note = arg.Note
+	}
+	t.postStars[key] = star
+	return nil
+}
+
+// TransferFeeds gives each feed added by a user to the other user who has
+// followed it the longest. Feeds that no one else follows are left unchanged.
+func (s *Store) TransferFeeds(ctx context.Context, arg database.TransferFeedsParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	var transferred int64
+	for id, feed := range t.feeds {
+		if feed.UserID != arg.UserID {
+			continue
+		}
+		var oldest *database.FeedFollow
+		for _, follow := range t.feedFollows {
+			if follow.FeedID != id || follow.UserID == arg.UserID {
+				continue
+			}
+			if oldest == nil || follow.CreatedAt.Before(oldest.CreatedAt) ||
+				(follow.CreatedAt.Equal(oldest.CreatedAt) && follow.ID.String() < oldest.ID.String()) {
+				oldest = &follow
+			}
+		}
+		if oldest == nil {
+			continue
+		}
+		feed.UserID = oldest.UserID
+		feed.UpdatedAt = arg.UpdatedAt
+		t.feeds[id] = feed
+		transferred++
+	}
+	return transferred, nil
+}
+
+// UnstarPost removes the star, and with it the note and tags, from a post.
+// It returns the number of stars removed.
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	key := userPostKey{arg.UserID, arg.PostID}
+	if _, ok := t.postStars[key]; !ok {
+		return 0, nil
+	}
+	t.deleteStar(key)
+	return 1, nil
+}
+
+// UpdateFeed changes the name and URL of a feed. Changing the URL also
+// clears LastFetchedAt, so the feed is fetched from its new URL next.
+func (s *Store) UpdateFeed(ctx context.Context, arg database.UpdateFeedParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	feed, ok := t.feeds[arg.ID]
+	if !ok {
+		return nil
+	}
+	if other, ok := t.feedByURL(arg.Url); ok && other.ID != feed.ID {
+		return fmt.Errorf("%w: feed url %s", ErrUniqueViolation, arg.Url)
+	}
+	if feed.Url != arg.Url {
+		feed.LastFetchedAt = sql.NullTime{}
+	}
+	feed.Name = arg.Name
+	feed.Url = arg.Url
+	feed.UpdatedAt = arg.UpdatedAt
+	t.feeds[feed.ID] = feed
+	return nil
+}
+
+// UpsertFeedCredentials inserts or replaces the credentials of a feed.
+func (s *Store) UpsertFeedCredentials(ctx context.Context, arg database.UpsertFeedCredentialsParams) error {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.feeds[arg.FeedID]; !ok {
+		return fmt.Errorf("%w: feed %s", ErrForeignKeyViolation, arg.FeedID)
+	}
+	creds := database.FeedCredential(arg)
+	if existing, ok := t.credentials[arg.FeedID]; ok {
+		creds.CreatedAt = existing.CreatedAt
+	}
+	t.credentials[arg.FeedID] = creds
+	return nil
+}
+
+// UpsertPosts inserts posts, or updates existing posts of the same feed with
+// the same URL whose title, description, content or publication time changed.
+// It returns true for each inserted post and false for each updated one;
+// unchanged posts, posts of other feeds and posts that were pruned are left
+// out.
+func (s *Store) UpsertPosts(ctx context.Context, arg database.UpsertPostsParams) ([]bool, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.feeds[arg.FeedID]; !ok {
+		return nil, fmt.Errorf("%w: feed %s", ErrForeignKeyViolation, arg.FeedID)
+	}
+
+	var results []bool
+	for i, id := range arg.Ids {
+		description := sql.NullString{String: arg.Descriptions[i], Valid: arg.Descriptions[i] != ""}
+		publishedAt := sql.NullTime{Time: arg.PublishedAts[i], Valid: !arg.PublishedAts[i].IsZero()}
+		content := sql.NullString{String: arg.Contents[i], Valid: arg.Contents[i] != ""}
+
+		if _, pruned := t.prunedPosts[prunedPostKey{feedID: arg.FeedID, url: arg.Urls[i]}]; pruned {
+			continue
+		}
+		existing, ok := t.postByURL(arg.Urls[i])
+		if !ok {
+			t.posts[id] = database.Post{
+				ID:          id,
+				CreatedAt:   arg.Now,
+				UpdatedAt:   arg.Now,
+				Title:       arg.Titles[i],
+				Url:         arg.Urls[i],
+				Description: description,
+				PublishedAt: publishedAt,
+				FeedID:      arg.FeedID,
+				Content:     content,
+			}
+			results = append(results, true)
+			continue
+		}
+
+		if existing.FeedID != arg.FeedID {
+			continue
+		}
+		if existing.Title == arg.Titles[i] && existing.Description == description && existing.Content == content &&
+			existing.PublishedAt.Valid == publishedAt.Valid && existing.PublishedAt.Time.Equal(publishedAt.Time) {
+			continue
+		}
+		existing.Title = arg.Titles[i]
+		existing.Description = description
+		existing.Content = content
+		existing.PublishedAt = publishedAt
+		existing.UpdatedAt = arg.Now
+		t.posts[existing.ID] = existing
+		results = append(results, false)
+	}
+	return results, nil
+}
+
+// UpsertWebSubSubscription inserts the WebSub subscription of a feed, or
+// updates the hub, topic and secret of an existing one, which then has to be
+// verified again.
+func (s *Store) UpsertWebSubSubscription(ctx context.Context, arg database.UpsertWebSubSubscriptionParams) (database.WebsubSubscription, error) {
+	t := s.lock()
+	defer s.mu.Unlock()
+
+	if _, ok := t.feeds[arg.FeedID]; !ok {
+		return database.WebsubSubscription{}, fmt.Errorf("%w: feed %s", ErrForeignKeyViolation, arg.FeedID)
+	}
+	for _, sub := range t.websubs {
+		if sub.FeedID == arg.FeedID {
+			sub.UpdatedAt = arg.UpdatedAt
+			sub.HubUrl = arg.HubUrl
+			sub.TopicUrl = arg.TopicUrl
+			sub.Secret = arg.Secret
+			sub.LeaseExpiresAt = sql.NullTime{}
+			t.websubs[sub.ID] = sub
+			return sub, nil
+		}
+	}
+
+	sub := database.WebsubSubscription{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		FeedID:    arg.FeedID,
+		HubUrl:    arg.HubUrl,
+		TopicUrl:  arg.TopicUrl,
+		Secret:    arg.Secret,
+	}
+	t.websubs[sub.ID] = sub
+	return sub, nil
+}
+
+// userByName finds a user by name.
+func (t *tables) userByName(name string) (database.User, bool) {
+	for _, user := range t.users {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return database.User{}, false
+}
+
+// feedByURL finds a feed by URL.
+func (t *tables) feedByURL(url string) (database.Feed, bool) {
+	for _, feed := range t.feeds {
+		if feed.Url == url {
+			return feed, true
+		}
+	}
+	return database.Feed{}, false
+}
+
+// followByURL finds a user's follow of the feed with the given URL.
+func (t *tables) followByURL(userID uuid.UUID, feedURL string) (database.FeedFollow, bool) {
+	for _, follow := range t.feedFollows {
+		if follow.UserID == userID && t.feeds[follow.FeedID].Url == feedURL {
+			return follow, true
+		}
+	}
+	return database.FeedFollow{}, false
+}
+
+// followName returns the name a follow's feed is shown with: the user's alias
+// for it if they gave one, or else the feed's name.
+func (t *tables) followName(follow database.FeedFollow) string {
+	if follow.Alias.Valid {
+		return follow.Alias.String
+	}
+	return t.feeds[follow.FeedID].Name
+}
+
+// feedName returns the name a user sees a feed under, whether or not they
+// follow it.
+func (t *tables) feedName(userID, feedID uuid.UUID) string {
+	for _, follow := range t.feedFollows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return t.followName(follow)
+		}
+	}
+	return t.feeds[feedID].Name
+}
+
+// folderByName finds a user's folder by name.
+func (t *tables) folderByName(userID uuid.UUID, name string) (database.Folder, bool) {
+	for _, folder := range t.folders {
+		if folder.UserID == userID && folder.Name == name {
+			return folder, true
+		}
+	}
+	return database.Folder{}, false
+}
+
+// folderName returns the name of the folder a follow is in, if any.
+func (t *tables) folderName(follow database.FeedFollow) sql.NullString {
+	if !follow.FolderID.Valid {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.folders[follow.FolderID.UUID].Name, Valid: true}
+}
+
+// postByURL finds a post by URL.
+func (t *tables) postByURL(url string) (database.Post, bool) {
+	for _, post := range t.posts {
+		if post.Url == url {
+			return post, true
+		}
+	}
+	return database.Post{}, false
+}
+
+// unreadCount counts the posts of a feed that a user has not read.
+func (t *tables) unreadCount(userID, feedID uuid.UUID) int64 {
+	var count int64
+	for _, post := range t.posts {
+		if _, read := t.postReads[userPostKey{userID, post.ID}]; post.FeedID == feedID && !read {
+			count++
+		}
+	}
+	return count
+}
+
+// matchesPostFilter reports whether a post matches the optional filters of
+// MarkPostsRead and MarkPostsUnread. Undated posts are filtered by the time
+// they were first saved.
+func (t *tables) matchesPostFilter(post database.Post, postURL, feedURL sql.NullString, before sql.NullTime) bool {
+	if postURL.Valid && post.Url != postURL.String {
+		return false
+	}
+	if feedURL.Valid && t.feeds[post.FeedID].Url != feedURL.String {
+		return false
+	}
+	if before.Valid {
+		published := post.CreatedAt
+		if post.PublishedAt.Valid {
+			published = post.PublishedAt.Time
+		}
+		if !published.Before(before.Time) {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteUser deletes a user with the sessions, feeds, follows, folders, read
+// states and stars that reference it.
+func (t *tables) deleteUser(id uuid.UUID) {
+	delete(t.users, id)
+	for hash, session := range t.sessions {
+		if session.UserID == id {
+			delete(t.sessions, hash)
+		}
+	}
+	for folderID, folder := range t.folders {
+		if folder.UserID == id {
+			t.deleteFolder(folderID)
+		}
+	}
+	for key := range t.postReads {
+		if key.userID == id {
+			delete(t.postReads, key)
+		}
+	}
+	for key := range t.postStars {
+		if key.userID == id {
+			t.deleteStar(key)
+		}
+	}
+	for feedID, feed := range t.feeds {
+		if feed.UserID == id {
+			t.deleteFeed(feedID)
+		}
+	}
+	for followID, follow := range t.feedFollows {
+		if follow.UserID == id {
+			delete(t.feedFollows, followID)
+		}
+	}
+}
+
+// deleteFeed deletes a feed with the rows that reference it.
+func (t *tables) deleteFeed(id uuid.UUID) {
+	delete(t.feeds, id)
+	delete(t.credentials, id)
+	for key := range t.prunedPosts {
+		if key.feedID == id {
+			delete(t.prunedPosts, key)
+		}
+	}
+	for followID, follow := range t.feedFollows {
+		if follow.FeedID == id {
+			delete(t.feedFollows, followID)
+		}
+	}
+	for postID, post := range t.posts {
+		if post.FeedID == id {
+			t.deletePost(postID)
+		}
+	}
+	for subID, sub := range t.websubs {
+		if sub.FeedID == id {
+			delete(t.websubs, subID)
+		}
+	}
+}
+
+// deleteFolder deletes a folder and takes the follows in it out of it.
+func (t *tables) deleteFolder(id uuid.UUID) {
+	delete(t.folders, id)
+	for followID, follow := range t.feedFollows {
+		if follow.FolderID.Valid && follow.FolderID.UUID == id {
+			follow.FolderID = uuid.NullUUID{}
+			t.feedFollows[followID] = follow
+		}
+	}
+}
+
+// deletePost deletes a post with the read states and stars that reference
+// it.
+func (t *tables) deletePost(id uuid.UUID) {
+	delete(t.posts, id)
+	for key := range t.postReads {
+		if key.postID == id {
+			delete(t.postReads, key)
+		}
+	}
+	for key := range t.postStars {
+		if key.postID == id {
+			t.deleteStar(key)
+		}
+	}
+}
+
+// deleteStar deletes a star with its tags.
+func (t *tables) deleteStar(key userPostKey) {
+	delete(t.postStars, key)
+	for tag := range t.starTags {
+		if tag.UserID == key.userID && tag.PostID == key.postID {
+			delete(t.starTags, tag)
+		}
+	}
+}
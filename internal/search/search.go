@@ -0,0 +1,169 @@
+// Package search parses post search queries written in the web search syntax
+// of PostgreSQL's websearch_to_tsquery, so that every storage backend reads
+// them the same way:
+//
+//	postgres vacuum        posts containing both words
+//	"full text search"     posts containing the phrase
+//	vacuum -autovacuum     posts containing vacuum but not autovacuum
+//	postgres or sqlite     posts containing either word
+//
+// OR binds looser than the implicit AND between terms.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Term is a word or a phrase, optionally negated.
+type Term struct {
+	Words   []string
+	Negated bool
+}
+
+// Query is a search query: a post matches if it matches any of the groups,
+// and it matches a group if it matches all of the group's terms.
+type Query struct {
+	Groups [][]Term
+}
+
+// Parse parses a query. Punctuation inside words separates them, as it does
+// for the search index, and words are lowercased.
+func Parse(query string) Query {
+	var q Query
+	var group []Term
+
+	endGroup := func() {
+		if len(group) > 0 {
+			q.Groups = append(q.Groups, group)
+			group = nil
+		}
+	}
+
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '"' || (r == '-' && i+1 < len(query) && query[i+1] == '"'):
+			negated := r == '-'
+			if negated {
+				i++
+			}
+			end := strings.IndexByte(query[i+1:], '"')
+			var phrase string
+			if end < 0 {
+				phrase, i = query[i+1:], len(query)
+			} else {
+				phrase, i = query[i+1:i+1+end], i+end+2
+			}
+			if words := Words(phrase); len(words) > 0 {
+				group = append(group, Term{Words: words, Negated: negated})
+			}
+		default:
+			end := strings.IndexFunc(query[i:], func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(query) - i
+			}
+			token := query[i : i+end]
+			i += end
+
+			if strings.EqualFold(token, "or") && len(group) > 0 {
+				endGroup()
+				continue
+			}
+			negated := strings.HasPrefix(token, "-")
+			for _, word := range Words(strings.TrimPrefix(token, "-")) {
+				group = append(group, Term{Words: []string{word}, Negated: negated})
+			}
+		}
+	}
+	endGroup()
+
+	return q
+}
+
+// Words splits text into lowercase words at anything that is not a letter or
+// a digit.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// IsEmpty reports whether the query can match anything: a query needs at
+// least one term that is not negated.
+func (q Query) IsEmpty() bool {
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if !term.Negated {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FTS5 formats the query as a SQLite FTS5 match expression. Groups without a
+// term that is not negated cannot be expressed and are left out.
+func (q Query) FTS5() string {
+	var groups []string
+	for _, group := range q.Groups {
+		var include, exclude []string
+		for _, term := range group {
+			quoted := `"` + strings.ReplaceAll(strings.Join(term.Words, " "), `"`, `""`) + `"`
+			if term.Negated {
+				exclude = append(exclude, quoted)
+			} else {
+				include = append(include, quoted)
+			}
+		}
+		if len(include) == 0 {
+			continue
+		}
+
+		expr := strings.Join(include, " AND ")
+		for _, term := range exclude {
+			expr = "(" + expr + ") NOT " + term
+		}
+		groups = append(groups, "("+expr+")")
+	}
+	return strings.Join(groups, " OR ")
+}
+
+// Match reports whether text matches the query. Words must match exactly;
+// unlike the database indexes, no stemming is done.
+func (q Query) Match(text string) bool {
+	words := Words(text)
+	for _, group := range q.Groups {
+		matched := true
+		for _, term := range group {
+			if containsPhrase(words, term.Words) == term.Negated {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPhrase reports whether words contains phrase as a contiguous run.
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		found := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
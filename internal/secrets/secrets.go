@@ -0,0 +1,84 @@
+// Package secrets encrypts small values, such as feed credentials, for storage
+// in the database. Values are sealed with AES-256-GCM under a key kept in the
+// gator config file.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size in bytes of an encryption key.
+const KeySize = 32
+
+// ErrNoKey is returned when a value must be encrypted or decrypted but no key
+// is configured.
+var ErrNoKey = errors.New("no encryption key configured")
+
+// GenerateKey returns a new random key, encoded as base64.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("Error generating key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt seals plaintext under the base64-encoded key. The additional data
+// is authenticated but not encrypted; the same value must be passed to Decrypt.
+func Encrypt(key string, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("Error generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the same key and
+// additional data.
+func Decrypt(key string, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("Error decrypting value: ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// newAEAD decodes the key and returns an AES-GCM cipher for it.
+func newAEAD(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, ErrNoKey
+	}
+
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding key: %w", err)
+	}
+	if len(rawKey) != KeySize {
+		return nil, fmt.Errorf("Error decoding key: expected %d bytes, got %d", KeySize, len(rawKey))
+	}
+
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"modernc.org/sqlite"
+
+	"github.com/Fepozopo/gator/internal/search"
+)
+
+func init() {
+	// websearch_to_fts5 lets the SQLite translations accept the same search
+	// queries as PostgreSQL's websearch_to_tsquery.
+	err := sqlite.RegisterDeterministicScalarFunction("websearch_to_fts5", 1, websearchToFTS5)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// websearchToFTS5 converts a search query to an FTS5 match expression.
+func websearchToFTS5(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	query, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("websearch_to_fts5: expected text, got %T", args[0])
+	}
+	return search.Parse(query).FTS5(), nil
+}
@@ -0,0 +1,206 @@
+// Package sqlite runs the queries generated by sqlc for PostgreSQL against a
+// SQLite database. Each query is identified by its "-- name:" comment and
+// replaced by a SQLite translation with the same parameters and result
+// columns, so the generated database package can be used unchanged.
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimeFormat is the layout SQLite timestamps are stored in. It sorts
+// chronologically for UTC times and is parsed back into time.Time by the
+// driver for TIMESTAMP columns.
+const TimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// DBTX is the subset of *sql.DB and *sql.Tx used by the generated queries.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// DB translates queries and their arguments before passing them to a SQLite
+// connection or transaction.
+type DB struct {
+	conn    DBTX
+	queries map[string]string
+}
+
+// New loads the SQLite translations from the .sql files at the root of
+// queries and returns a DB that runs them on conn.
+func New(conn DBTX, queries fs.FS) (*DB, error) {
+	files, err := fs.Glob(queries, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make(map[string]string)
+	for _, file := range files {
+		data, err := fs.ReadFile(queries, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for name, query := range splitQueries(string(data)) {
+			if _, ok := translations[name]; ok {
+				return nil, fmt.Errorf("query %s is defined more than once", name)
+			}
+			translations[name] = query
+		}
+	}
+
+	return &DB{conn: conn, queries: translations}, nil
+}
+
+// WithTx returns a DB that runs the same translations inside tx.
+func (db *DB) WithTx(tx *sql.Tx) *DB {
+	return &DB{conn: tx, queries: db.queries}
+}
+
+// ExecContext runs the translation of query.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.ExecContext(ctx, db.translate(query), convertArgs(args)...)
+}
+
+// PrepareContext prepares the translation of query. Arguments passed to the
+// returned statement are not converted.
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.conn.PrepareContext(ctx, db.translate(query))
+}
+
+// QueryContext runs the translation of query.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.conn.QueryContext(ctx, db.translate(query), convertArgs(args)...)
+}
+
+// QueryRowContext runs the translation of query.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.conn.QueryRowContext(ctx, db.translate(query), convertArgs(args)...)
+}
+
+// translate returns the SQLite translation of a generated query. Queries
+// without a translation are replaced by one that fails with the query name,
+// since running PostgreSQL syntax against SQLite would fail less clearly.
+func (db *DB) translate(query string) string {
+	name := queryName(query)
+	if translation, ok := db.queries[name]; ok {
+		return translation
+	}
+	return fmt.Sprintf("SELECT * FROM \"no SQLite translation for query %s\"", name)
+}
+
+// queryName returns the name from the "-- name: X :kind" comment that starts
+// every generated query.
+func queryName(query string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "--" || fields[1] != "name:" {
+		return ""
+	}
+	return fields[2]
+}
+
+// splitQueries splits a file of named queries into a map from name to query.
+// Each query keeps its "-- name:" comment.
+func splitQueries(data string) map[string]string {
+	queries := make(map[string]string)
+	var name string
+	var query strings.Builder
+
+	flush := func() {
+		if name != "" {
+			queries[name] = strings.TrimSpace(query.String())
+		}
+		query.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "-- name:") {
+			flush()
+			name = queryName(line)
+		}
+		query.WriteString(line)
+		query.WriteByte('\n')
+	}
+	flush()
+
+	return queries
+}
+
+// convertArgs converts arguments to values SQLite can store and compare:
+// times are stored in UTC, and PostgreSQL arrays become JSON arrays that the
+// translations read with json_each.
+func convertArgs(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		converted[i] = convertArg(arg)
+	}
+	return converted
+}
+
+// convertArg converts a single argument.
+func convertArg(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.UTC()
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.UTC()
+	case pq.GenericArray:
+		return jsonArray(reflect.ValueOf(v.A))
+	case *pq.StringArray, *pq.Int64Array, *pq.Int32Array, *pq.Float64Array,
+		*pq.Float32Array, *pq.BoolArray, *pq.ByteaArray:
+		return jsonArray(reflect.ValueOf(v))
+	}
+	return arg
+}
+
+// jsonArray encodes a slice, or a pointer to one, as a JSON array. Times are
+// formatted like stored timestamps, with the zero time as null, and other
+// values are encoded by their driver value where they have one.
+func jsonArray(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	elems := make([]interface{}, v.Len())
+	for i := range elems {
+		elem := v.Index(i).Interface()
+		switch e := elem.(type) {
+		case time.Time:
+			if e.IsZero() {
+				elems[i] = nil
+			} else {
+				elems[i] = e.UTC().Format(TimeFormat)
+			}
+		case driver.Valuer:
+			value, err := e.Value()
+			if err != nil {
+				elems[i] = nil
+			} else {
+				elems[i] = value
+			}
+		default:
+			elems[i] = elem
+		}
+	}
+
+	data, _ := json.Marshal(elems)
+	return string(data)
+}
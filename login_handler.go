@@ -2,36 +2,98 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
 )
 
 // handlerLogin handles the "login" command, which logs in to the given
-// username. The username is taken from the command arguments. If the username
-// does not exist in the database, an error is returned. If the username exists,
-// the user is set as the current user in the configuration, and a success
-// message is printed with the user name.
+// username. The username is taken from the command arguments and the password
+// is prompted for. If the username does not exist or the password is wrong,
+// an error is returned. Otherwise a new session is started, its token is
+// stored in the configuration, and a success message is printed with the
+// user name.
+//
+// Users created before passwords were introduced have no password yet, and
+// cannot log in until an admin sets one with "user set-password". Only the
+// admin of such an install may choose a password at login, and only while no
+// user has one, so that the install is not locked out after upgrading.
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) < 1 {
-		return errors.New("username is required")
-	}
 	username := cmd.args[0]
 
 	// Check if the user exists in the database
 	user, err := s.db.GetUser(context.Background(), username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("invalid username or password")
+	}
 	if err != nil {
-		if err.Error() == "sql: now rows in result set" {
-			return fmt.Errorf("user '%s' does not exist", username)
-		}
 		return fmt.Errorf("failed to fetch user: %w", err)
 	}
 
-	// Set the current user in the config
-	err = s.cfg.SetUser(user.Name)
-	if err != nil {
-		return fmt.Errorf("failed to set user: %w", err)
+	if user.PasswordHash.Valid {
+		// Check the password
+		password, err := readPassword("Password: ")
+		if err != nil {
+			return err
+		}
+		if !checkPassword(user.PasswordHash.String, password) {
+			return errors.New("invalid username or password")
+		}
+	} else {
+		firstLogin, err := isFirstPasswordLogin(s, user)
+		if err != nil {
+			return err
+		}
+		if !firstLogin {
+			return fmt.Errorf("user '%s' has no password yet; ask an admin to run 'user set-password %s'", username, username)
+		}
+
+		// Let the admin choose a password
+		fmt.Printf("User '%s' has no password yet, please choose one.\n", username)
+		password, err := readNewPassword()
+		if err != nil {
+			return err
+		}
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		err = s.db.SetUserPassword(context.Background(), database.SetUserPasswordParams{
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+			UpdatedAt:    time.Now(),
+			ID:           user.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set password: %w", err)
+		}
+	}
+
+	// Start a session for the user
+	if err := startSession(s, user); err != nil {
+		return err
 	}
 
 	fmt.Printf("Logged in as '%s'\n", username)
 	return nil
 }
+
+// isFirstPasswordLogin reports whether a user without a password may choose
+// one at login: the user is an admin and no user has a password yet.
+func isFirstPasswordLogin(s *state, user database.User) (bool, error) {
+	if !user.IsAdmin {
+		return false, nil
+	}
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	for _, other := range users {
+		if other.PasswordHash.Valid {
+			return false, nil
+		}
+	}
+	return true, nil
+}
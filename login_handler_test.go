@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// setTestInput makes the password prompts read the given lines, and points
+// the config file at a temporary home directory.
+func setTestInput(t *testing.T, lines ...string) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	previous := stdin
+	stdin = bufio.NewReader(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+	t.Cleanup(func() {
+		stdin = previous
+	})
+}
+
+// setTestPassword gives the user a password.
+func setTestPassword(t *testing.T, s *state, user database.User, password string) database.User {
+	t.Helper()
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	user.PasswordHash = sql.NullString{String: hash, Valid: true}
+	err = s.db.SetUserPassword(context.Background(), database.SetUserPasswordParams{
+		PasswordHash: user.PasswordHash,
+		UpdatedAt:    time.Now(),
+		ID:           user.ID,
+	})
+	if err != nil {
+		t.Fatalf("SetUserPassword: %v", err)
+	}
+	return user
+}
+
+// makeTestAdmin makes the user an admin.
+func makeTestAdmin(t *testing.T, s *state, user database.User) database.User {
+	t.Helper()
+
+	_, err := s.db.SetUserAdmin(context.Background(), database.SetUserAdminParams{
+		IsAdmin:   true,
+		UpdatedAt: time.Now(),
+		Name:      user.Name,
+	})
+	if err != nil {
+		t.Fatalf("SetUserAdmin: %v", err)
+	}
+	user.IsAdmin = true
+	return user
+}
+
+func TestLoginChecksPassword(t *testing.T) {
+	s := newTestState(t)
+	setTestPassword(t, s, createTestUser(t, s, "alice"), "correct horse")
+
+	setTestInput(t, "wrong horse")
+	if err := handlerLogin(s, command{name: "login", args: []string{"alice"}}); err == nil {
+		t.Error("logged in with a wrong password")
+	}
+
+	setTestInput(t, "correct horse")
+	var err error
+	captureStdout(t, func() {
+		err = handlerLogin(s, command{name: "login", args: []string{"alice"}})
+	})
+	if err != nil {
+		t.Fatalf("handlerLogin: %v", err)
+	}
+	if s.cfg.CurrentUserName != "alice" || s.cfg.SessionToken == "" {
+		t.Errorf("no session was started for alice: %+v", s.cfg)
+	}
+}
+
+func TestLoginWithoutPassword(t *testing.T) {
+	s := newTestState(t)
+	admin := makeTestAdmin(t, s, createTestUser(t, s, "admin"))
+	createTestUser(t, s, "legacy")
+
+	// Nobody may claim an account without a password
+	setTestInput(t, "attacker password")
+	err := handlerLogin(s, command{name: "login", args: []string{"legacy"}})
+	if err == nil || !strings.Contains(err.Error(), "user set-password legacy") {
+		t.Fatalf("handlerLogin: got %v, want a pointer to set-password", err)
+	}
+
+	// The admin of an upgraded install chooses the first password
+	setTestInput(t, "admin password")
+	captureStdout(t, func() {
+		err = handlerLogin(s, command{name: "login", args: []string{"admin"}})
+	})
+	if err != nil {
+		t.Fatalf("handlerLogin: %v", err)
+	}
+	if _, err := s.db.GetSessionUser(context.Background(), database.GetSessionUserParams{
+		TokenHash: hashSessionToken(s.cfg.SessionToken),
+		Now:       time.Now(),
+	}); err != nil {
+		t.Errorf("no session for %s: %v", admin.Name, err)
+	}
+
+	// Once anyone has a password, admins without one are refused as well
+	second := makeTestAdmin(t, s, createTestUser(t, s, "second"))
+	setTestInput(t, "second password")
+	if err := handlerLogin(s, command{name: "login", args: []string{second.Name}}); err == nil {
+		t.Error("an admin without a password chose one although another user has a password")
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// handlerLogout handles the "logout" command, which ends the current session
+// and removes it from the configuration. It works even if the session has
+// already expired.
+func handlerLogout(s *state, cmd command) error {
+	if s.cfg.SessionToken == "" {
+		return errNotLoggedIn
+	}
+
+	if err := s.db.DeleteSession(context.Background(), hashSessionToken(s.cfg.SessionToken)); err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+
+	name := s.cfg.CurrentUserName
+	if err := s.cfg.ClearSession(); err != nil {
+		return fmt.Errorf("failed to clear session: %w", err)
+	}
+
+	fmt.Printf("Logged out '%s'\n", name)
+	return nil
+}
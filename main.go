@@ -1,14 +1,10 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"os"
 
-	_ "github.com/lib/pq"
-
 	config "github.com/Fepozopo/gator/internal/config"
-	database "github.com/Fepozopo/gator/internal/database"
 )
 
 func main() {
@@ -16,6 +12,34 @@ func main() {
 }
 
 func main_helper() int {
+	// Parse command-line arguments against the registered commands, taking
+	// out the global --output option
+	args, output, err := extractOutputOption(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	cmds := newCommands()
+	cmd, err := cmds.parse(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Help needs neither the config nor the database
+	if cmd.name == "help" {
+		if len(os.Args) < 2 {
+			// No command was given at all
+			cmds.printHelp(os.Stderr, "")
+			return 1
+		}
+		if err := cmds.run(nil, cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
 	// Load configuration
 	cfg, err := config.Read()
 	if err != nil {
@@ -24,46 +48,40 @@ func main_helper() int {
 	}
 
 	// Connect to the database
-	db, err := sql.Open("postgres", cfg.DbURL)
+	conn, err := connectDatabase(cfg.DbURL)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to connect to the database: %v\n", err)
 		return 1
 	}
-	defer db.Close()
+	defer conn.sqlDB.Close()
 
-	// Initialize the database queries
-	dbQueries := database.New(db)
+	// Initialize the storage over the database
+	store := newSQLStorage(conn)
+
+	// Initialize the shared HTTP client for fetching feeds
+	feedFetcher, err := newFetcher(cfg.HTTP)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring HTTP client: %v\n", err)
+		return 1
+	}
 
 	// Initialize application state
 	appState := &state{
-		db:  dbQueries,
-		cfg: &cfg,
+		db:      store,
+		cfg:     &cfg,
+		conn:    conn,
+		output:  output,
+		fetcher: feedFetcher,
 	}
 
-	// Initialize commands and register handlers
-	cmds := &commands{}
-	cmds.register("login", handlerLogin)
-	cmds.register("register", handlerRegister)
-	cmds.register("reset", handlerReset)
-	cmds.register("users", handlerUsers)
-	cmds.register("agg", handlerAgg)
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", handlerFeeds)
-	cmds.register("follow", middlewareLoggedIn(handlerFollow))
-	cmds.register("following", middlewareLoggedIn(handlerFollowing))
-	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
-
-	// Parse command-line arguments
-	if len(os.Args) < 2 {
-		fmt.Fprint(os.Stderr, "Error: not enough arguments provided\n")
-		return 1
+	// Make sure the database schema matches this binary
+	if cmd.name != "migrate" {
+		if err := checkSchema(conn); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
 	}
 
-	cmdName := os.Args[1]
-	cmdArgs := os.Args[2:]
-	cmd := command{name: cmdName, args: cmdArgs}
-
 	// Run the command
 	if err := cmds.run(appState, cmd); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -73,3 +91,360 @@ func main_helper() int {
 	// No errors, return 0
 	return 0
 }
+
+// newCommands returns the registry of all commands, with their usage, flags
+// and handlers.
+func newCommands() *commands {
+	cmds := &commands{}
+
+	cmds.register(commandSpec{
+		name:    "help",
+		summary: "Show help for gator or one of its commands",
+		usage:   []string{"[<command>]"},
+		maxArgs: 1,
+		handler: func(s *state, cmd command) error {
+			name := ""
+			if len(cmd.args) > 0 {
+				name = cmd.args[0]
+			}
+			return cmds.printHelp(os.Stdout, name)
+		},
+	})
+
+	// Accounts
+	cmds.register(commandSpec{
+		name:    "register",
+		summary: "Create a user account and log in",
+		usage:   []string{"<username>"},
+		description: `The password is read from the terminal, or from standard input when it is
+not a terminal. The first user to register becomes an admin.`,
+		minArgs: 1,
+		maxArgs: 1,
+		handler: handlerRegister,
+	})
+	cmds.register(commandSpec{
+		name:    "login",
+		summary: "Log in as a user",
+		usage:   []string{"<username>"},
+		description: `The password is read from the terminal, or from standard input when it is
+not a terminal. Users created before passwords existed choose one on their
+first login.`,
+		minArgs: 1,
+		maxArgs: 1,
+		handler: handlerLogin,
+	})
+	cmds.register(commandSpec{
+		name:    "logout",
+		summary: "Log out the current user",
+		handler: handlerLogout,
+	})
+	cmds.register(commandSpec{
+		name:    "users",
+		summary: "List all users",
+		handler: handlerUsers,
+	})
+	cmds.register(commandSpec{
+		name:    "user",
+		summary: "Rename or delete a user account, or set its password",
+		usage: []string{
+			"rename [<username>] <new_name>",
+			"delete [<username>] [--yes]",
+			"set-password [<username>]",
+		},
+		description: `Without a username, the command acts on the current user. Only admins can
+manage other users. Changing your own password asks for the current one;
+admins set passwords for users who have none, who cannot log in until then.`,
+		minArgs: 1,
+		maxArgs: 3,
+		flags: []flagSpec{
+			{name: "yes", kind: boolFlag, usage: "Delete without asking for confirmation"},
+		},
+		handler: middlewareLoggedIn(handlerUser),
+	})
+	cmds.register(commandSpec{
+		name:        "admin",
+		summary:     "Make a user an admin, or no longer an admin",
+		usage:       []string{"grant <username>", "revoke <username>"},
+		description: `Only admins can run this command. The last admin cannot be revoked.`,
+		minArgs:     2,
+		maxArgs:     2,
+		handler:     middlewareAdmin(handlerAdmin),
+	})
+	cmds.register(commandSpec{
+		name:    "reset",
+		summary: "Delete all users, feeds and posts",
+		description: `Only admins can run this command. It asks for confirmation unless --yes
+is given.`,
+		flags: []flagSpec{
+			{name: "posts-only", kind: boolFlag, usage: "Delete only posts nobody starred, keeping users, feeds, follows and stars"},
+			{name: "yes", kind: boolFlag, usage: "Delete without asking for confirmation"},
+		},
+		handler: middlewareAdmin(handlerReset),
+	})
+
+	// Feeds
+	cmds.register(commandSpec{
+		name:    "agg",
+		summary: "Fetch feeds continuously",
+		usage:   []string{"<time_between_reqs>"},
+		description: `Fetches the feed that was fetched the longest ago every time_between_reqs,
+e.g. 30s or 1m, until interrupted. Content pushed by WebSub hubs is also
+received when a WebSub listen address is configured.`,
+		minArgs: 1,
+		maxArgs: 1,
+		flags: []flagSpec{
+			{name: "prune-every", kind: durationFlag, value: "<duration>", usage: "Also prune old posts at startup and then at this interval"},
+		},
+		handler: handlerAgg,
+	})
+	cmds.register(commandSpec{
+		name:    "addfeed",
+		summary: "Add a feed and follow it",
+		usage:   []string{"<name> <url>"},
+		description: `If a feed with the URL already exists, it is followed instead, under the
+given name as your own if it differs from the feed's name.`,
+		minArgs: 2,
+		maxArgs: 2,
+		handler: middlewareLoggedIn(handlerAddFeed),
+	})
+	cmds.register(commandSpec{
+		name:    "feeds",
+		summary: "List all feeds",
+		handler: handlerFeeds,
+	})
+	cmds.register(commandSpec{
+		name:    "feed",
+		summary: "Edit or delete a feed",
+		usage: []string{
+			"edit <feed_url> [--name <name>] [--url <new_url> [--keep-credentials]]",
+			"delete <feed_url> [--yes]",
+		},
+		description: `Editing a feed changes it for every follower. Only the user who added a
+feed, or an admin, can edit or delete it. Moving a feed to another host
+deletes its credentials unless --keep-credentials is given.`,
+		minArgs: 2,
+		maxArgs: 2,
+		flags: []flagSpec{
+			{name: "name", kind: stringFlag, value: "<name>", usage: "New name of the feed"},
+			{name: "url", kind: stringFlag, value: "<new_url>", usage: "New URL of the feed"},
+			{name: "keep-credentials", kind: boolFlag, usage: "Keep the credentials when the new URL is on another host"},
+			{name: "yes", kind: boolFlag, usage: "Delete without asking for confirmation"},
+		},
+		handler: middlewareLoggedIn(handlerFeed),
+	})
+	cmds.register(commandSpec{
+		name:    "feedauth",
+		summary: "Manage the credentials and headers sent when fetching a feed",
+		usage: []string{
+			"<feed_url> show",
+			"<feed_url> basic <username> <password>",
+			"<feed_url> bearer <token>",
+			"<feed_url> cookie <cookie>",
+			"<feed_url> header <name> <value>",
+			"<feed_url> unheader <name>",
+			"<feed_url> clear",
+		},
+		description: `Only the user who added a feed can change or view its credentials. Secret
+values are encrypted in the database and never printed. Put -- before
+values that start with --, so that they are not taken for flags.`,
+		minArgs: 2,
+		maxArgs: 4,
+		handler: middlewareLoggedIn(handlerFeedAuth),
+	})
+	cmds.register(commandSpec{
+		name:    "retention",
+		summary: "Show or change how long posts are kept",
+		usage: []string{
+			"",
+			"<feed_url>",
+			"<feed_url> [--max-age <age>|none|default] [--max-posts <n>|none|default]",
+		},
+		description: `Ages are given in days or weeks, like 90d or 12w. "none" keeps posts however
+old or many they are, and "default" uses the retention from the config file.
+Only the user who added a feed can change its retention.`,
+		maxArgs: 1,
+		flags: []flagSpec{
+			{name: "max-age", kind: stringFlag, value: "<age>", usage: "Delete posts older than this"},
+			{name: "max-posts", kind: stringFlag, value: "<n>", usage: "Keep only this many of the newest posts"},
+		},
+		handler: middlewareLoggedIn(handlerRetention),
+	})
+	cmds.register(commandSpec{
+		name:        "prune",
+		summary:     "Delete old posts according to the retention settings",
+		description: `Starred posts are kept. Only admins can run this command.`,
+		flags: []flagSpec{
+			{name: "dry-run", kind: boolFlag, usage: "Count the posts that would be deleted, but keep them"},
+			{name: "feed", kind: stringFlag, value: "<feed_url>", usage: "Prune only this feed"},
+		},
+		handler: middlewareAdmin(handlerPrune),
+	})
+
+	// Following
+	cmds.register(commandSpec{
+		name:    "follow",
+		summary: "Follow a feed",
+		usage:   []string{"<feed_url>"},
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerFollow),
+	})
+	cmds.register(commandSpec{
+		name:    "following",
+		summary: "List the feeds you follow",
+		flags: []flagSpec{
+			{name: "folder", kind: stringFlag, value: "<folder>", usage: "List only the feeds in this folder"},
+		},
+		handler: middlewareLoggedIn(handlerFollowing),
+	})
+	cmds.register(commandSpec{
+		name:    "unfollow",
+		summary: "Stop following a feed",
+		usage:   []string{"<feed_url>"},
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerUnfollow),
+	})
+	cmds.register(commandSpec{
+		name:        "rename-follow",
+		summary:     "Give a feed you follow a name of your own",
+		usage:       []string{"<feed_url> [<name>]"},
+		description: `Without a name, the feed is shown under its shared name again.`,
+		minArgs:     1,
+		maxArgs:     2,
+		handler:     middlewareLoggedIn(handlerRenameFollow),
+	})
+	cmds.register(commandSpec{
+		name:    "folder",
+		summary: "Sort the feeds you follow into folders",
+		usage: []string{
+			"add <folder>",
+			"mv <feed_url>... <folder>",
+			"rm <folder> [<feed_url>...]",
+			"ls",
+		},
+		description: `Each followed feed is in at most one folder. Removing a folder keeps its
+feeds; removing feeds from a folder takes them out of it.`,
+		minArgs: 1,
+		maxArgs: -1,
+		handler: middlewareLoggedIn(handlerFolder),
+	})
+	cmds.register(commandSpec{
+		name:    "import",
+		summary: "Follow the feeds listed in an OPML file",
+		usage:   []string{"opml <file>"},
+		minArgs: 2,
+		maxArgs: 2,
+		handler: middlewareLoggedIn(handlerImport),
+	})
+	cmds.register(commandSpec{
+		name:    "export",
+		summary: "Print the feeds you follow as OPML",
+		usage:   []string{"opml"},
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerExport),
+	})
+
+	// Posts
+	cmds.register(commandSpec{
+		name:        "browse",
+		summary:     "List the newest unread posts",
+		usage:       []string{"[<limit>]"},
+		description: `Lists 2 posts unless a limit is given.`,
+		maxArgs:     1,
+		flags: []flagSpec{
+			{name: "all", kind: boolFlag, usage: "Include posts that were already read"},
+			{name: "folder", kind: stringFlag, value: "<folder>", usage: "List only posts from the feeds in this folder"},
+		},
+		handler: middlewareLoggedIn(handlerBrowse),
+	})
+	cmds.register(commandSpec{
+		name:        "search",
+		summary:     "Search the posts of the feeds you follow",
+		usage:       []string{"<query>..."},
+		description: searchHelp,
+		minArgs:     1,
+		maxArgs:     -1,
+		flags: []flagSpec{
+			{name: "feed", kind: stringFlag, value: "<feed_url>", usage: "Search only this feed"},
+			{name: "since", kind: stringFlag, value: "<date>", usage: "Only posts published on or after this date"},
+			{name: "until", kind: stringFlag, value: "<date>", usage: "Only posts published before this date"},
+			{name: "limit", kind: intFlag, value: "<n>", usage: fmt.Sprintf("Show at most n results (default %d)", defaultSearchLimit)},
+		},
+		handler: middlewareLoggedIn(handlerSearch),
+	})
+	cmds.register(commandSpec{
+		name:        "read",
+		summary:     "Mark posts as read",
+		usage:       []string{"<post_url>", "[--feed <feed_url>] [--before <date>]", "--all"},
+		description: postSelectionHelp,
+		maxArgs:     1,
+		flags:       postSelectionFlags,
+		handler:     middlewareLoggedIn(handlerRead),
+	})
+	cmds.register(commandSpec{
+		name:        "unread",
+		summary:     "Mark posts as unread",
+		usage:       []string{"<post_url>", "[--feed <feed_url>] [--before <date>]", "--all"},
+		description: postSelectionHelp,
+		maxArgs:     1,
+		flags:       postSelectionFlags,
+		handler:     middlewareLoggedIn(handlerUnread),
+	})
+	cmds.register(commandSpec{
+		name:    "star",
+		summary: "Star a post, optionally with a note and tags",
+		usage:   []string{"<post_id|post_url>"},
+		description: `Starring a post that is already starred replaces its note, if one is given,
+and adds or removes tags. Several tags may be given at once, separated by
+commas. Starred posts are kept when old posts are pruned.`,
+		minArgs: 1,
+		maxArgs: 1,
+		flags: []flagSpec{
+			{name: "note", kind: stringFlag, value: "<text>", usage: "Note to keep with the post"},
+			{name: "tag", kind: stringsFlag, value: "<tag>", usage: "Add a tag"},
+			{name: "untag", kind: stringsFlag, value: "<tag>", usage: "Remove a tag"},
+		},
+		handler: middlewareLoggedIn(handlerStar),
+	})
+	cmds.register(commandSpec{
+		name:    "unstar",
+		summary: "Remove a post from your starred posts",
+		usage:   []string{"<post_id|post_url>"},
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerUnstar),
+	})
+	cmds.register(commandSpec{
+		name:    "starred",
+		summary: "List your starred posts",
+		flags: []flagSpec{
+			{name: "tag", kind: stringFlag, value: "<tag>", usage: "List only posts with this tag"},
+			{name: "export", kind: stringFlag, value: "markdown|json", choices: []string{"markdown", "json"}, usage: "Export the list as markdown or json"},
+		},
+		handler: middlewareLoggedIn(handlerStarred),
+	})
+
+	// Database
+	cmds.register(commandSpec{
+		name:    "migrate",
+		summary: "Manage the database schema",
+		usage:   []string{"up|down|status|redo"},
+		description: `  up      apply all pending migrations
+  down    roll back the most recent migration
+  status  list all migrations and whether they have been applied
+  redo    roll back and re-apply the most recent migration
+
+down and redo ask for confirmation unless --yes is given. Once the database
+has users, only admins can run them.`,
+		minArgs: 1,
+		maxArgs: 1,
+		flags: []flagSpec{
+			{name: "yes", kind: boolFlag, usage: "Roll back without asking for confirmation"},
+		},
+		handler: handlerMigrate,
+	})
+
+	return cmds
+}
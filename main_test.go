@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	config "github.com/Fepozopo/gator/internal/config"
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/memstore"
+	"github.com/google/uuid"
+)
+
+// newTestState returns a state over an empty in-memory store, with a fetcher
+// that may reach the loopback servers started by tests.
+func newTestState(t *testing.T) *state {
+	t.Helper()
+
+	feedFetcher, err := newFetcher(config.HTTPConfig{AllowedNetworks: []string{"127.0.0.0/8", "::1"}})
+	if err != nil {
+		t.Fatalf("newFetcher: %v", err)
+	}
+	return &state{
+		db:      memstore.New(),
+		cfg:     &config.Config{},
+		output:  outputText,
+		fetcher: feedFetcher,
+	}
+}
+
+// newSQLiteTestState returns a state like newTestState, but over a migrated
+// SQLite database in a temporary directory.
+func newSQLiteTestState(t *testing.T) *state {
+	t.Helper()
+
+	conn, err := connectDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatalf("connectDatabase: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.sqlDB.Close()
+	})
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		t.Fatalf("newMigrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating up: %v", err)
+	}
+
+	s := newTestState(t)
+	s.db = newSQLStorage(conn)
+	s.conn = conn
+	return s
+}
+
+// createTestUser creates a user in the state's store.
+func createTestUser(t *testing.T, s *state, name string) database.User {
+	t.Helper()
+
+	now := time.Now()
+	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      name,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// createTestFeed creates a feed added by the user in the state's store.
+func createTestFeed(t *testing.T, s *state, user database.User, name, url string) database.Feed {
+	t.Helper()
+
+	now := time.Now()
+	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      name,
+		Url:       url,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	return feed
+}
+
+// followTestFeed makes the user follow the feed.
+func followTestFeed(t *testing.T, s *state, user database.User, feed database.Feed) {
+	t.Helper()
+
+	now := time.Now()
+	_, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+}
+
+// captureStdout runs fn and returns what it printed to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+// testRSS is a small RSS document with the given items, each given as a
+// title and link.
+func testRSS(items ...[2]string) string {
+	doc := `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title><link>http://example.com/</link>`
+	for _, item := range items {
+		doc += "<item><title>" + item[0] + "</title><link>" + item[1] + "</link>" +
+			"<pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate></item>"
+	}
+	return doc + "</channel></rss>"
+}
@@ -1,35 +1,35 @@
 package main
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/Fepozopo/gator/internal/database"
-	"github.com/google/uuid"
 )
 
 // middlewareLoggedIn wraps a command handler with middleware that checks if the user is logged in before executing the handler.
+// The user is identified by the session token in the config, which must belong to a session that has not expired.
 // If the user is not logged in, the middleware returns an error.
 // If the user is logged in, the middleware passes the state, command, and current user to the wrapped handler.
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
-		// Fetch the current user's name from the config
-		if s.cfg.CurrentUserName == "" {
-			return fmt.Errorf("no user is currently logged in")
-		}
-
-		// Retrieve the current user from the database
-		currentUser, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+		// Retrieve the current user from the session
+		currentUser, err := sessionUser(s)
 		if err != nil {
-			return fmt.Errorf("failed to fetch current user: %w", err)
-		}
-
-		// Ensure the user exists
-		if currentUser.ID == uuid.Nil {
-			return fmt.Errorf("no logged-in user found")
+			return err
 		}
 
 		// Pass the state, command, and current user to the wrapped handler
-		return handler(s, cmd, *&currentUser)
+		return handler(s, cmd, currentUser)
 	}
 }
+
+// middlewareAdmin wraps a command handler like middlewareLoggedIn, but only runs the handler if the current user is an admin.
+// It is used for commands that act on the data of all users.
+func middlewareAdmin(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
+	return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+		if !user.IsAdmin {
+			return fmt.Errorf("only admins can run '%s'", cmd.name)
+		}
+		return handler(s, cmd, user)
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// adminsVersion is the version of the migration that introduces admins.
+const adminsVersion = 15
+
+// handlerMigrate handles the "migrate" command, which manages the database
+// schema using the migrations embedded in the binary. It takes one argument:
+//
+//	up:     apply all pending migrations
+//	down:   roll back the most recent migration
+//	status: list all migrations and whether they have been applied
+//	redo:   roll back and re-apply the most recent migration
+//
+// Rolling back can lose data, so down and redo must be confirmed
+// interactively or with --yes, and need an admin once the database has users.
+func handlerMigrate(s *state, cmd command) error {
+	if s.conn == nil {
+		return errors.New("migrations need a database connection")
+	}
+	migrator, err := newMigrator(s.conn)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	action := cmd.args[0]
+	if action == "down" || action == "redo" {
+		if err := checkRollbackAllowed(ctx, s, migrator, cmd); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "up":
+		results, err := migrator.Up(ctx)
+		printMigrationResults(results)
+		if err != nil {
+			return fmt.Errorf("failed to migrate up: %w", err)
+		}
+		if len(results) == 0 {
+			fmt.Print("Database schema is up to date.\n")
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate down: %w", err)
+		}
+		fmt.Println(result)
+	case "redo":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to roll back: %w", err)
+		}
+		fmt.Println(result)
+		result, err = migrator.UpByOne(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to re-apply: %w", err)
+		}
+		fmt.Println(result)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-20s %s\n", appliedAt, status.Source.Path)
+		}
+	default:
+		return newUsageError("unknown action %q", cmd.args[0])
+	}
+
+	return nil
+}
+
+// checkRollbackAllowed returns an error unless the current user may roll
+// back the most recent migration and confirmed it. Before the admins
+// migration is applied, or while there are no users, anyone may; afterwards
+// only admins can.
+func checkRollbackAllowed(ctx context.Context, s *state, migrator *goose.Provider, cmd command) error {
+	version, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database version: %w", err)
+	}
+	if version >= adminsVersion {
+		var users int
+		if err := s.conn.sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM users").Scan(&users); err != nil {
+			return fmt.Errorf("failed to count users: %w", err)
+		}
+		if users > 0 {
+			user, err := sessionUser(s)
+			if err != nil {
+				return err
+			}
+			if !user.IsAdmin {
+				return fmt.Errorf("only admins can run 'migrate %s'", cmd.args[0])
+			}
+		}
+	}
+
+	if !cmd.boolFlag("yes") {
+		ok, err := confirm(fmt.Sprintf("This rolls back migration %d and may delete data. Continue?", version))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("migration cancelled")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateWithoutDatabase(t *testing.T) {
+	s := newTestState(t)
+
+	if err := handlerMigrate(s, command{name: "migrate", args: []string{"status"}}); err == nil {
+		t.Error("handlerMigrate succeeded without a database connection")
+	}
+}
+
+func TestMigrateRollbackNeedsAdminAndConfirmation(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLiteTestState(t)
+	migrator, err := newMigrator(s.conn)
+	if err != nil {
+		t.Fatalf("newMigrator: %v", err)
+	}
+	latest, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetDBVersion: %v", err)
+	}
+	down := command{name: "migrate", args: []string{"down"}, flags: map[string]any{"yes": true}}
+
+	// Without --yes, the rollback has to be confirmed
+	setTestInput(t, "n")
+	captureStdout(t, func() {
+		err = handlerMigrate(s, command{name: "migrate", args: []string{"down"}})
+	})
+	if err == nil {
+		t.Error("rolled back without confirmation")
+	}
+
+	// Once there are users, only admins may roll back
+	alice := createTestUser(t, s, "alice")
+	bob := makeTestAdmin(t, s, createTestUser(t, s, "bob"))
+	if err := handlerMigrate(s, down); !errors.Is(err, errNotLoggedIn) {
+		t.Errorf("rolling back without being logged in: got %v, want errNotLoggedIn", err)
+	}
+	if err := startSession(s, alice); err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	if err := handlerMigrate(s, down); err == nil || !strings.Contains(err.Error(), "only admins") {
+		t.Errorf("rolling back as a user who is not an admin: got %v", err)
+	}
+	if version, _ := migrator.GetDBVersion(ctx); version != latest {
+		t.Fatalf("database version is %d, want %d", version, latest)
+	}
+
+	if err := startSession(s, bob); err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	captureStdout(t, func() {
+		err = handlerMigrate(s, down)
+	})
+	if err != nil {
+		t.Fatalf("migrate down as an admin: %v", err)
+	}
+	if version, _ := migrator.GetDBVersion(ctx); version != latest-1 {
+		t.Errorf("database version is %d, want %d", version, latest-1)
+	}
+}
+
+func TestNormalizeURLsMigration(t *testing.T) {
+	conn, err := connectDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatalf("connectDatabase: %v", err)
+	}
+	defer conn.sqlDB.Close()
+	m, err := newMigrator(conn)
+	if err != nil {
+		t.Fatalf("newMigrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.UpTo(ctx, normalizeURLsVersion-1); err != nil {
+		t.Fatalf("migrating up to %d: %v", normalizeURLsVersion-1, err)
+	}
+
+	// Rows stored before URLs were normalized
+	for _, stmt := range []string{
+		`INSERT INTO users (id, name) VALUES ('u1', 'alice')`,
+		`INSERT INTO feeds (id, created_at, updated_at, name, url, user_id) VALUES
+			('f1', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'One', 'HTTPS://Example.com:443/feed#top', 'u1'),
+			('f2', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Two', 'https://example.org/feed', 'u1'),
+			('f3', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Three', 'https://example.org/feed?utm_source=x', 'u1')`,
+		`INSERT INTO posts (id, created_at, updated_at, title, url, feed_id) VALUES
+			('p1', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Post', 'https://example.com/post?b=2&a=1', 'f1')`,
+		`INSERT INTO pruned_posts (url, feed_id, pruned_at) VALUES
+			('https://example.com/old#x', 'f1', CURRENT_TIMESTAMP)`,
+	} {
+		if _, err := conn.sqlDB.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("inserting rows: %v", err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrating up: %v", err)
+	}
+
+	for query, want := range map[string]string{
+		`SELECT url FROM feeds WHERE id = 'f1'`:             "https://example.com/feed",
+		`SELECT url FROM feeds WHERE id = 'f3'`:             "https://example.org/feed?utm_source=x", // normalized form taken by f2
+		`SELECT url FROM posts WHERE id = 'p1'`:             "https://example.com/post?a=1&b=2",
+		`SELECT url FROM pruned_posts WHERE feed_id = 'f1'`: "https://example.com/old",
+	} {
+		var got string
+		if err := conn.sqlDB.QueryRowContext(ctx, query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", query, got, want)
+		}
+	}
+}
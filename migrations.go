@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+)
+
+// schemaFS holds the goose migrations from sql/schema and their SQLite
+// translations from sql/sqlite/schema, so the binary can set up and upgrade
+// its own database.
+//
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var schemaFS embed.FS
+
+// newMigrator returns a goose provider for the embedded migrations of the
+// connection's dialect, together with the Go migrations. It uses the same goose_db_version table as the goose
+// CLI, so databases migrated by hand are recognized.
+func newMigrator(conn *dbConn) (*goose.Provider, error) {
+	dir := "sql/schema"
+	if conn.dialect == goose.DialectSQLite3 {
+		dir = "sql/sqlite/schema"
+	}
+
+	migrations, err := fs.Sub(schemaFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return goose.NewProvider(conn.dialect, conn.sqlDB, migrations,
+		goose.WithGoMigrations(normalizeURLsMigration()))
+}
+
+// checkSchema verifies that the database schema matches the migrations
+// embedded in the binary. If migrations are pending and stdin is a terminal,
+// the user is offered to apply them; otherwise an error tells them to run
+// "gator migrate up". A schema newer than the binary is always an error.
+func checkSchema(conn *dbConn) error {
+	ctx := context.Background()
+
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	current, target, err := migrator.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	if current > target {
+		return fmt.Errorf("database schema version %d is newer than this gator supports (%d); upgrade gator", current, target)
+	}
+	if current == target {
+		return nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("database schema version %d is behind the required version %d; run 'gator migrate up'", current, target)
+	}
+
+	fmt.Printf("Database schema version %d is behind the required version %d. Migrate now? [y/N] ", current, target)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("database schema is out of date; run 'gator migrate up'")
+	}
+
+	results, err := migrator.Up(ctx)
+	printMigrationResults(results)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
+}
+
+// printMigrationResults prints one line per applied or rolled back migration.
+func printMigrationResults(results []*goose.MigrationResult) {
+	for _, result := range results {
+		fmt.Println(result)
+	}
+}
+
+// isTerminal reports whether the file is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// opmlDocument is an OPML subscription list, the format feed readers use to
+// import and export the feeds a user follows.
+type opmlDocument struct {
+	XMLName  xml.Name       `xml:"opml"`
+	Version  string         `xml:"version,attr"`
+	Title    string         `xml:"head>title"`
+	Created  string         `xml:"head>dateCreated,omitempty"`
+	Outlines []*opmlOutline `xml:"body>outline"`
+}
+
+// opmlOutline is an entry in an OPML document. An outline with an xmlUrl is a
+// feed; any other outline is a category grouping the outlines nested in it.
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+// opmlEntry is a feed listed in an OPML document, with the folder it belongs
+// in. Nested categories are joined with "/" into a single folder name, and
+// feeds outside any category have no folder.
+type opmlEntry struct {
+	Name   string
+	URL    string
+	Folder string
+}
+
+// parseOPML reads the feeds listed in an OPML document, in document order.
+// The document is parsed with the same limits as fetched feeds.
+func parseOPML(r io.Reader) ([]opmlEntry, error) {
+	var doc opmlDocument
+	if err := newGuardedDecoder(r, defaultXMLLimits).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid OPML: %w", err)
+	}
+
+	var entries []opmlEntry
+	var walk func(outlines []*opmlOutline, folder string)
+	walk = func(outlines []*opmlOutline, folder string) {
+		for _, outline := range outlines {
+			name := strings.TrimSpace(outline.Text)
+			if name == "" {
+				name = strings.TrimSpace(outline.Title)
+			}
+
+			if outline.XMLURL != "" {
+				entries = append(entries, opmlEntry{Name: name, URL: outline.XMLURL, Folder: folder})
+				continue
+			}
+
+			category := folder
+			if name != "" && category != "" {
+				category += "/" + name
+			} else if name != "" {
+				category = name
+			}
+			walk(outline.Outlines, category)
+		}
+	}
+	walk(doc.Outlines, "")
+	return entries, nil
+}
+
+// writeOPML writes a user's follows as an OPML document. Follows in a folder
+// are nested in a category outline for it, split into nested categories
+// at each "/" so that the folders are the same when the document is imported
+// again.
+func writeOPML(w io.Writer, title string, follows []database.GetFeedFollowsForUserRow) error {
+	doc := opmlDocument{
+		Version: "2.0",
+		Title:   title,
+		Created: time.Now().Format(time.RFC1123Z),
+	}
+
+	categories := make(map[string]*opmlOutline)
+	var category func(path string) *[]*opmlOutline
+	category = func(path string) *[]*opmlOutline {
+		if path == "" {
+			return &doc.Outlines
+		}
+		if outline, ok := categories[path]; ok {
+			return &outline.Outlines
+		}
+		parent, name := "", path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		outline := &opmlOutline{Text: name, Title: name}
+		siblings := category(parent)
+		*siblings = append(*siblings, outline)
+		categories[path] = outline
+		return &outline.Outlines
+	}
+
+	for _, follow := range follows {
+		outlines := category(follow.FolderName.String)
+		*outlines = append(*outlines, &opmlOutline{
+			Text:   follow.FeedName,
+			Title:  follow.FeedName,
+			Type:   "rss",
+			XMLURL: follow.FeedUrl,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
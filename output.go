@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputFormat is the format listing commands print in, chosen with the
+// global --output option.
+type outputFormat string
+
+// Output formats. The text format is the default, meant for people; the
+// others print the same fields under stable names for scripts.
+const (
+	outputText  outputFormat = "text"
+	outputJSON  outputFormat = "json"
+	outputJSONL outputFormat = "jsonl"
+	outputCSV   outputFormat = "csv"
+	outputTable outputFormat = "table"
+)
+
+// structured reports whether the format is meant for scripts rather than
+// people. The zero value is the text format.
+func (f outputFormat) structured() bool {
+	return f != "" && f != outputText
+}
+
+// extractOutputOption removes the global --output option from the command
+// line arguments, given as "--output <format>" or "--output=<format>" before
+// or after the command name, and returns the remaining arguments with the
+// chosen format. Arguments from "--" on are left to the command untouched,
+// so that a positional value such as a search query can still be "--output".
+func extractOutputOption(args []string) ([]string, outputFormat, error) {
+	format := outputText
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		value, ok := strings.CutPrefix(args[i], "--output=")
+		if !ok {
+			if args[i] != "--output" {
+				rest = append(rest, args[i])
+				continue
+			}
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("--output requires a format: text, json, jsonl, csv or table")
+			}
+			i++
+			value = args[i]
+		}
+
+		switch f := outputFormat(value); f {
+		case outputText, outputJSON, outputJSONL, outputCSV, outputTable:
+			format = f
+		default:
+			return nil, "", fmt.Errorf("invalid output format %q: use text, json, jsonl, csv or table", value)
+		}
+	}
+	return rest, format, nil
+}
+
+// writeRecords writes records, which must be structs, in a structured output
+// format. Fields are named by their json tags. Timestamps are written in
+// ISO 8601 format, nil pointers as null or empty values, and lists of
+// strings as comma-separated values in CSV and tables.
+func writeRecords[T any](w io.Writer, format outputFormat, records []T) error {
+	switch format {
+	case outputJSON:
+		if records == nil {
+			records = []T{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+
+	case outputJSONL:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case outputCSV:
+		names, fields := recordFields(reflect.TypeFor[T]())
+		writer := csv.NewWriter(w)
+		if err := writer.Write(names); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writer.Write(recordValues(reflect.ValueOf(record), fields, time.RFC3339Nano)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case outputTable:
+		names, fields := recordFields(reflect.TypeFor[T]())
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(names, "\t")))
+		for _, record := range records {
+			values := recordValues(reflect.ValueOf(record), fields, time.RFC3339)
+			for i, value := range values {
+				// Keep each record on one line
+				values[i] = strings.Join(strings.Fields(value), " ")
+			}
+			fmt.Fprintln(writer, strings.Join(values, "\t"))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+// recordFields returns the column names of a record type, taken from the json
+// tags of its fields, and the indexes of those fields.
+func recordFields(t reflect.Type) ([]string, []int) {
+	var names []string
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+		fields = append(fields, i)
+	}
+	return names, fields
+}
+
+// recordValues formats the given fields of a record for CSV and tables,
+// formatting timestamps with timeLayout.
+func recordValues(record reflect.Value, fields []int, timeLayout string) []string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = formatValue(record.Field(field), timeLayout)
+	}
+	return values
+}
+
+// formatValue formats a single field value for CSV and tables.
+func formatValue(value reflect.Value, timeLayout string) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return v.Format(timeLayout)
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// minPasswordLength is the minimum number of characters in a password.
+const minPasswordLength = 8
+
+// stdin reads passwords when standard input is not a terminal, so that
+// several passwords can be piped in one after the other.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassword prompts for a password and reads it without echoing it. When
+// standard input is not a terminal, the password is read from the next line
+// of input instead.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no password given")
+		}
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassword prompts for a new password, asking for it twice when
+// standard input is a terminal, and checks that it is long enough.
+func readNewPassword() (string, error) {
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return "", err
+	}
+	if len([]rune(password)) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		confirmation, err := readPassword("Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+		if confirmation != password {
+			return "", errors.New("passwords do not match")
+		}
+	}
+	return password, nil
+}
+
+// hashPassword hashes a password with bcrypt for storing it in the database.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", errors.New("password must be at most 72 bytes long")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches a hash made by
+// hashPassword.
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
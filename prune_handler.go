@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerPrune handles the "prune" command, which deletes old posts according
+// to the retention configured by default and for each feed. With --feed, only
+// that feed is pruned. With --dry-run, the posts that would be deleted are
+// counted but kept. Only admins can prune, since it deletes the posts of all
+// users.
+func handlerPrune(s *state, cmd command, user database.User) error {
+	dryRun := cmd.boolFlag("dry-run")
+	feedURL := ""
+	if value, ok := cmd.stringFlag("feed"); ok {
+		normalized, err := normalizeURL(value)
+		if err != nil {
+			return err
+		}
+		feedURL = normalized
+	}
+
+	var feeds []database.Feed
+	if feedURL != "" {
+		feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+		if err != nil {
+			return fmt.Errorf("feed not found: %w", err)
+		}
+		feeds = append(feeds, feed)
+	} else {
+		all, err := s.db.GetFeeds(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to fetch feeds: %w", err)
+		}
+		feeds = all
+	}
+
+	total, err := pruneFeeds(s, feeds, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("Would prune %d post(s) in total; run without --dry-run to delete them.\n", total)
+	} else {
+		fmt.Printf("Pruned %d post(s) in total.\n", total)
+	}
+	return nil
+}
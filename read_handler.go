@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// postSelection selects the posts that "read" and "unread" apply to. Unset
+// fields do not restrict the selection.
+type postSelection struct {
+	PostURL sql.NullString
+	FeedURL sql.NullString
+	Before  sql.NullTime
+}
+
+// postSelectionFlags are the flags shared by "read" and "unread".
+var postSelectionFlags = []flagSpec{
+	{name: "feed", kind: stringFlag, value: "<feed_url>", usage: "Mark all posts of a feed"},
+	{name: "before", kind: stringFlag, value: "<date>", usage: "Mark all posts published before a date"},
+	{name: "all", kind: boolFlag, usage: "Mark all posts"},
+}
+
+// postSelectionHelp describes the selectors of "read" and "unread".
+const postSelectionHelp = `Posts are selected by URL, by feed, by publication date, or all at once.
+--feed and --before may be combined. Dates are YYYY-MM-DD, RFC 3339
+timestamps, or ages like 30d or 2w.`
+
+// parsePostSelection parses the selectors shared by "read" and "unread": a
+// post URL, --feed and --before, which may be combined, or --all. Exactly one
+// kind of selector is required, so that all posts are only ever marked on
+// purpose.
+func parsePostSelection(cmd command) (postSelection, error) {
+	var sel postSelection
+	if len(cmd.args) > 0 {
+		postURL, err := normalizeURL(cmd.args[0])
+		if err != nil {
+			return sel, err
+		}
+		sel.PostURL = sql.NullString{String: postURL, Valid: true}
+	}
+	if value, ok := cmd.stringFlag("feed"); ok {
+		feedURL, err := normalizeURL(value)
+		if err != nil {
+			return sel, err
+		}
+		sel.FeedURL = sql.NullString{String: feedURL, Valid: true}
+	}
+	if value, ok := cmd.stringFlag("before"); ok {
+		before, _, err := parseDate(value)
+		if err != nil {
+			return sel, err
+		}
+		sel.Before = sql.NullTime{Time: before, Valid: true}
+	}
+
+	selectors := 0
+	for _, set := range []bool{cmd.boolFlag("all"), sel.PostURL.Valid, sel.FeedURL.Valid || sel.Before.Valid} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors == 0 {
+		return sel, newUsageError("no posts selected")
+	}
+	if selectors > 1 {
+		return sel, newUsageError("give either a post URL, --feed and --before, or --all")
+	}
+	return sel, nil
+}
+
+// handlerRead handles the "read" command, which marks posts from the feeds
+// the current user follows as read, so that "browse" no longer shows them.
+func handlerRead(s *state, cmd command, user database.User) error {
+	sel, err := parsePostSelection(cmd)
+	if err != nil {
+		return err
+	}
+
+	marked, err := s.db.MarkPostsRead(context.Background(), database.MarkPostsReadParams{
+		ReadAt:  time.Now(),
+		UserID:  user.ID,
+		PostUrl: sel.PostURL,
+		FeedUrl: sel.FeedURL,
+		Before:  sel.Before,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark posts as read: %w", err)
+	}
+
+	fmt.Printf("Marked %d post(s) as read.\n", marked)
+	return nil
+}
+
+// handlerUnread handles the "unread" command, which marks posts the current
+// user has read as unread again.
+func handlerUnread(s *state, cmd command, user database.User) error {
+	sel, err := parsePostSelection(cmd)
+	if err != nil {
+		return err
+	}
+
+	marked, err := s.db.MarkPostsUnread(context.Background(), database.MarkPostsUnreadParams{
+		UserID:  user.ID,
+		PostUrl: sel.PostURL,
+		FeedUrl: sel.FeedURL,
+		Before:  sel.Before,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark posts as unread: %w", err)
+	}
+
+	fmt.Printf("Marked %d post(s) as unread.\n", marked)
+	return nil
+}
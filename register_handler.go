@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"errors"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -11,40 +11,58 @@ import (
 )
 
 // handlerRegister handles the "register" command, which creates a new user
-// with the given username. The username is taken from the command arguments.
-// If the username already exists, an error is returned. Upon successful
-// creation, the user is set as the current user in the configuration, and
-// a success message is printed with the user details.
+// with the given username. The username is taken from the command arguments
+// and the password is prompted for. If the username already exists, an error
+// is returned. The first user becomes an admin. Upon successful creation, the
+// user is logged in, and a success message is printed with the user details.
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) < 1 {
-		return errors.New("username is required")
-	}
 	name := cmd.args[0]
 
+	// Ask for the user's password
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
 	// Create a new user
 	newUser := database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      name,
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         name,
+		PasswordHash: sql.NullString{String: hash, Valid: true},
 	}
 
-	// Use the generated query to insert the user
-	user, err := s.db.CreateUser(context.Background(), newUser)
+	// Use the generated query to insert the user, making them an admin if
+	// there is none yet
+	var user database.User
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		admins, err := q.CountAdmins(context.Background())
+		if err != nil {
+			return err
+		}
+		newUser.IsAdmin = admins == 0
+		user, err = q.CreateUser(context.Background(), newUser)
+		return err
+	})
 	if err != nil {
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_name_key\"" {
+		if isUniqueViolation(err) {
 			return fmt.Errorf("a user with the name '%s' already exists", name)
 		}
 		return fmt.Errorf("failed to create user: %w\n", err)
 	}
 
-	// Set the current user in the config
-	if err := s.cfg.SetUser(user.Name); err != nil {
-		return fmt.Errorf("failed to set current user: %w", err)
+	// Log the new user in
+	if err := startSession(s, user); err != nil {
+		return err
 	}
 
 	// Print a success message and log the user details
 	fmt.Printf("User '%s' created successfully\n", user.Name)
-	fmt.Printf("User Details: %+v\n", user)
+	fmt.Printf("User Details: {ID:%s CreatedAt:%s Name:%s IsAdmin:%t}\n", user.ID, user.CreatedAt, user.Name, user.IsAdmin)
 	return nil
 }
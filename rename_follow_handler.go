@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerRenameFollow handles the "rename-follow" command, which gives a feed
+// the current user follows a name of their own. The name is shown instead of
+// the feed's shared name in "following", "feeds", "search" and "starred", and
+// only to this user. Without a name, the feed is shown under its shared name
+// again.
+func handlerRenameFollow(s *state, cmd command, user database.User) error {
+	feedURL, err := normalizeURL(cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	var alias sql.NullString
+	if len(cmd.args) == 2 {
+		if name := strings.TrimSpace(cmd.args[1]); name != "" {
+			alias = sql.NullString{String: name, Valid: true}
+		}
+	}
+
+	renamed, err := s.db.SetFeedFollowAlias(context.Background(), database.SetFeedFollowAliasParams{
+		Alias:     alias,
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		FeedUrl:   feedURL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename feed: %w", err)
+	}
+	if renamed == 0 {
+		return fmt.Errorf("you are not following %s", redactURL(feedURL))
+	}
+
+	if alias.Valid {
+		fmt.Printf("You now see %s as '%s'\n", redactURL(feedURL), alias.String)
+	} else {
+		fmt.Printf("You now see %s under its shared name\n", redactURL(feedURL))
+	}
+	return nil
+}
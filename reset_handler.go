@@ -2,14 +2,55 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Fepozopo/gator/internal/database"
 )
 
-// handlerReset handles the "reset" command, which deletes all users in the database.
-// It executes a query to remove all entries from the users table. If the operation
-// is successful, a confirmation message is printed. If the operation fails, an error
-// message is returned.
-func handlerReset(s *state, cmd command) error {
+// handlerReset handles the "reset" command, which deletes all users in the database
+// and, through them, all feeds and posts. With --posts-only, only the posts
+// nobody starred are deleted, keeping users, feeds, follows and stars. Only
+// admins can reset the database,
+// and the reset must be confirmed interactively or with --yes. If the operation
+// is successful, a confirmation message is printed. If the operation fails, an
+// error message is returned.
+func handlerReset(s *state, cmd command, user database.User) error {
+	postsOnly := cmd.boolFlag("posts-only")
+	yes := cmd.boolFlag("yes")
+
+	what := "all users, feeds and posts"
+	if postsOnly {
+		what = "all posts that nobody starred, keeping users, feeds, follows and stars"
+	}
+	if !yes {
+		ok, err := confirm(fmt.Sprintf("This deletes %s. Continue?", what))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("reset cancelled")
+		}
+	}
+
+	if postsOnly {
+		// Forget pruned posts too, so the feeds are fetched in full again
+		err := s.db.InTx(context.Background(), func(q database.Querier) error {
+			if err := q.DeleteUnstarredPosts(context.Background()); err != nil {
+				return err
+			}
+			return q.ForgetPrunedPosts(context.Background())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete posts: %w", err)
+		}
+
+		fmt.Print("All posts that nobody starred have been successfully deleted.\n")
+		return nil
+	}
+
 	// Call the query to delete all users
 	err := s.db.DeleteAllUsers(context.Background())
 	if err != nil {
@@ -19,3 +60,20 @@ func handlerReset(s *state, cmd command) error {
 	fmt.Print("All users have been successfully deleted.\n")
 	return nil
 }
+
+// confirm asks a yes/no question on the terminal and reports whether it was
+// answered with yes. It returns an error when standard input is not a
+// terminal, so commands that need confirmation must be given --yes instead.
+func confirm(question string) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("confirmation required; run in a terminal or pass --yes")
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		return false, nil
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
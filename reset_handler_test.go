@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+func TestResetPostsOnlyKeepsStarredPosts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	alice := makeTestAdmin(t, s, createTestUser(t, s, "alice"))
+	feed := createTestFeed(t, s, alice, "Blog", "http://blog.example.com/feed.xml")
+	_, err := savePosts(s, feed, newRSSFeed(strings.NewReader(testRSS(
+		[2]string{"Starred", "http://blog.example.com/starred"},
+		[2]string{"Plain", "http://blog.example.com/plain"},
+	))))
+	if err != nil {
+		t.Fatalf("savePosts: %v", err)
+	}
+	starred, err := s.db.GetPostByUrl(ctx, "http://blog.example.com/starred")
+	if err != nil {
+		t.Fatalf("GetPostByUrl: %v", err)
+	}
+	err = s.db.StarPost(ctx, database.StarPostParams{
+		UserID:    alice.ID,
+		PostID:    starred.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Note:      sql.NullString{String: "keep this", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("StarPost: %v", err)
+	}
+
+	cmd := command{name: "reset", flags: map[string]any{"posts-only": true, "yes": true}}
+	captureStdout(t, func() {
+		err = handlerReset(s, cmd, alice)
+	})
+	if err != nil {
+		t.Fatalf("handlerReset: %v", err)
+	}
+
+	if _, err := s.db.GetPostByUrl(ctx, "http://blog.example.com/plain"); err == nil {
+		t.Error("a post nobody starred was kept")
+	}
+	stars, err := s.db.GetStarredPostsForUser(ctx, database.GetStarredPostsForUserParams{UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("GetStarredPostsForUser: %v", err)
+	}
+	if len(stars) != 1 || stars[0].Note.String != "keep this" {
+		t.Errorf("got stars %+v, want the starred post with its note", stars)
+	}
+}
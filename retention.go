@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/config"
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// retentionPolicy is how long the posts of a feed are kept. Zero fields do
+// not limit retention.
+type retentionPolicy struct {
+	MaxAgeDays int
+	MaxPosts   int
+}
+
+// feedRetention returns the retention policy of a feed: the feed's own
+// settings where it has them, and the configured defaults otherwise.
+func feedRetention(defaults config.RetentionConfig, feed database.Feed) retentionPolicy {
+	policy := retentionPolicy{
+		MaxAgeDays: defaults.MaxAgeDays,
+		MaxPosts:   defaults.MaxPostsPerFeed,
+	}
+	if feed.RetentionMaxAgeDays.Valid {
+		policy.MaxAgeDays = int(feed.RetentionMaxAgeDays.Int32)
+	}
+	if feed.RetentionMaxPosts.Valid {
+		policy.MaxPosts = int(feed.RetentionMaxPosts.Int32)
+	}
+	return policy
+}
+
+// String describes the policy for printing.
+func (p retentionPolicy) String() string {
+	var limits []string
+	if p.MaxAgeDays > 0 {
+		limits = append(limits, fmt.Sprintf("posts older than %d days", p.MaxAgeDays))
+	}
+	if p.MaxPosts > 0 {
+		limits = append(limits, fmt.Sprintf("all but the newest %d posts", p.MaxPosts))
+	}
+	if len(limits) == 0 {
+		return "keep all posts"
+	}
+	return "prune " + strings.Join(limits, " and ")
+}
+
+// pruneFeeds deletes the posts of the given feeds that their retention
+// policies no longer keep, and returns the number of posts deleted. Starred
+// posts are never deleted. The URLs of deleted posts are remembered so that
+// fetching the feed again does not bring them back. With dryRun, the posts
+// are only counted.
+func pruneFeeds(s *state, feeds []database.Feed, dryRun bool) (int, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	total := 0
+	for _, feed := range feeds {
+		policy := feedRetention(s.cfg.Retention, feed)
+
+		params := database.GetPostsToPruneParams{FeedID: feed.ID}
+		if policy.MaxAgeDays > 0 {
+			params.Before = sql.NullTime{Time: now.AddDate(0, 0, -policy.MaxAgeDays), Valid: true}
+		}
+		if policy.MaxPosts > 0 {
+			params.Keep = sql.NullInt32{Int32: int32(policy.MaxPosts), Valid: true}
+		}
+		if !params.Before.Valid && !params.Keep.Valid {
+			continue
+		}
+
+		ids, err := s.db.GetPostsToPrune(ctx, params)
+		if err != nil {
+			return total, fmt.Errorf("failed to find posts to prune in %s: %w", feed.Name, err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		if !dryRun {
+			err = s.db.InTx(ctx, func(q database.Querier) error {
+				err := q.RememberPrunedPosts(ctx, database.RememberPrunedPostsParams{
+					PrunedAt: now,
+					Ids:      ids,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to remember pruned posts: %w", err)
+				}
+				if _, err := q.DeletePosts(ctx, ids); err != nil {
+					return fmt.Errorf("failed to delete posts: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				return total, fmt.Errorf("failed to prune %s: %w", feed.Name, err)
+			}
+		}
+
+		verb := "Pruned"
+		if dryRun {
+			verb = "Would prune"
+		}
+		fmt.Printf("%s %d post(s) from %s (%s)\n", verb, len(ids), feed.Name, policy)
+		total += len(ids)
+	}
+	return total, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// retentionRecord is the retention of a feed as printed by "retention" in
+// structured output formats. Zero limits keep posts however old or many they
+// are.
+type retentionRecord struct {
+	Feed        string `json:"feed"`
+	URL         string `json:"url"`
+	MaxAgeDays  int    `json:"max_age_days"`
+	MaxPosts    int    `json:"max_posts"`
+	OwnSettings bool   `json:"own_settings"`
+}
+
+// handlerRetention handles the "retention" command, which shows how long
+// posts are kept, by default and for each feed, and lets the user who added a
+// feed give it a retention of its own. Posts are deleted by "prune", or by
+// "agg" with --prune-every.
+func handlerRetention(s *state, cmd command, user database.User) error {
+	maxAge, hasMaxAge := cmd.stringFlag("max-age")
+	maxPosts, hasMaxPosts := cmd.stringFlag("max-posts")
+	if len(cmd.args) == 0 {
+		if hasMaxAge || hasMaxPosts {
+			return newUsageError("missing feed URL")
+		}
+		return printRetention(s)
+	}
+
+	feedURL, err := normalizeURL(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("feed not found: %w", err)
+	}
+	if !hasMaxAge && !hasMaxPosts {
+		fmt.Printf("%s: %s\n", feed.Name, feedRetention(s.cfg.Retention, feed))
+		return nil
+	}
+
+	if feed.UserID != user.ID {
+		return errors.New("only the user who added the feed can change its retention")
+	}
+
+	params := database.SetFeedRetentionParams{
+		RetentionMaxAgeDays: feed.RetentionMaxAgeDays,
+		RetentionMaxPosts:   feed.RetentionMaxPosts,
+		UpdatedAt:           time.Now(),
+		ID:                  feed.ID,
+	}
+	if hasMaxAge {
+		params.RetentionMaxAgeDays, err = parseRetentionLimit(maxAge, parseAge)
+		if err != nil {
+			return err
+		}
+	}
+	if hasMaxPosts {
+		params.RetentionMaxPosts, err = parseRetentionLimit(maxPosts, func(value string) (int, bool) {
+			n, err := strconv.Atoi(value)
+			return n, err == nil && n > 0
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.SetFeedRetention(context.Background(), params); err != nil {
+		return fmt.Errorf("failed to set retention: %w", err)
+	}
+
+	feed.RetentionMaxAgeDays = params.RetentionMaxAgeDays
+	feed.RetentionMaxPosts = params.RetentionMaxPosts
+	fmt.Printf("%s: %s\n", feed.Name, feedRetention(s.cfg.Retention, feed))
+	return nil
+}
+
+// parseRetentionLimit parses a retention limit given on the command line.
+// "default" is returned as NULL and "none" as 0; other values are parsed with
+// parse.
+func parseRetentionLimit(value string, parse func(string) (int, bool)) (sql.NullInt32, error) {
+	switch value {
+	case "default":
+		return sql.NullInt32{}, nil
+	case "none":
+		return sql.NullInt32{Int32: 0, Valid: true}, nil
+	}
+	n, ok := parse(value)
+	if !ok || n <= 0 {
+		return sql.NullInt32{}, newUsageError("invalid retention limit %q", value)
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}, nil
+}
+
+// printRetention prints the default retention and the retention of each
+// feed.
+func printRetention(s *state) error {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch feeds: %w", err)
+	}
+
+	if s.output.structured() {
+		records := make([]retentionRecord, 0, len(feeds))
+		for _, feed := range feeds {
+			policy := feedRetention(s.cfg.Retention, feed)
+			records = append(records, retentionRecord{
+				Feed:        feed.Name,
+				URL:         redactURL(feed.Url),
+				MaxAgeDays:  policy.MaxAgeDays,
+				MaxPosts:    policy.MaxPosts,
+				OwnSettings: feed.RetentionMaxAgeDays.Valid || feed.RetentionMaxPosts.Valid,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	fmt.Printf("Default: %s\n", feedRetention(s.cfg.Retention, database.Feed{}))
+	for _, feed := range feeds {
+		own := ""
+		if feed.RetentionMaxAgeDays.Valid || feed.RetentionMaxPosts.Valid {
+			own = " (own settings)"
+		}
+		fmt.Printf("* %s: %s%s\n", feed.Name, feedRetention(s.cfg.Retention, feed), own)
+	}
+	return nil
+}
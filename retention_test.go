@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// datedRSS is testRSS with every item published at the given time.
+func datedRSS(published time.Time, items ...[2]string) string {
+	return strings.ReplaceAll(testRSS(items...), "Mon, 02 Jan 2006 15:04:05 -0700", published.Format(time.RFC1123Z))
+}
+
+// saveTestPosts saves the items of an RSS document as posts of the feed.
+func saveTestPosts(t *testing.T, s *state, feed database.Feed, doc string) ingestStats {
+	t.Helper()
+
+	stats, err := savePosts(s, feed, newRSSFeed(strings.NewReader(doc)))
+	if err != nil {
+		t.Fatalf("savePosts: %v", err)
+	}
+	return stats
+}
+
+func TestPrunedPostsAreRememberedPerFeed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	s.cfg.Retention.MaxPostsPerFeed = 1
+	alice := createTestUser(t, s, "alice")
+	first := createTestFeed(t, s, alice, "First", "http://first.example.com/feed.xml")
+	second := createTestFeed(t, s, alice, "Second", "http://second.example.com/feed.xml")
+	old := testRSS([2]string{"Old", "http://example.com/old"})
+	recent := datedRSS(time.Now(), [2]string{"Recent", "http://example.com/recent"})
+
+	saveTestPosts(t, s, first, old)
+	saveTestPosts(t, s, first, recent)
+	var pruned int
+	var err error
+	captureStdout(t, func() {
+		pruned, err = pruneFeeds(s, []database.Feed{first}, false)
+	})
+	if err != nil {
+		t.Fatalf("pruneFeeds: %v", err)
+	}
+	if pruned != 1 {
+		t.Fatalf("pruned %d posts, want 1", pruned)
+	}
+	if _, err := s.db.GetPostByUrl(ctx, "http://example.com/old"); err == nil {
+		t.Fatal("the oldest post was not pruned")
+	}
+
+	// Fetching the feed again does not bring the post back
+	if stats := saveTestPosts(t, s, first, old); stats.New != 0 {
+		t.Errorf("a pruned post was saved again: %v", stats)
+	}
+
+	// Another feed may still save a post with the URL
+	if stats := saveTestPosts(t, s, second, old); stats.New != 1 {
+		t.Errorf("the pruned URL of another feed was not saved: %v", stats)
+	}
+}
+
+func TestSavePostsSkipsItemsOlderThanMaxAge(t *testing.T) {
+	s := newTestState(t)
+	s.cfg.Retention.MaxAgeDays = 30
+	alice := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, alice, "Blog", "http://blog.example.com/feed.xml")
+
+	stats := saveTestPosts(t, s, feed, testRSS([2]string{"Old", "http://example.com/old"}))
+	if stats != (ingestStats{Skipped: 1}) {
+		t.Errorf("old item: got %v, want 1 skipped", stats)
+	}
+	stats = saveTestPosts(t, s, feed, datedRSS(time.Now().AddDate(0, 0, -29), [2]string{"Recent", "http://example.com/recent"}))
+	if stats != (ingestStats{New: 1}) {
+		t.Errorf("recent item: got %v, want 1 new", stats)
+	}
+}
@@ -7,14 +7,37 @@ import (
 	"fmt"
 	"html"
 	"io"
+	"iter"
 	"net/http"
+	"slices"
+	"strings"
 )
 
+// atomNamespace is the XML namespace of <atom:link> elements in RSS feeds.
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// RSSFeed is an RSS document that is parsed while it is read. The channel
+// fields are filled in as they are encountered; items are produced one at a
+// time by Items, so a feed of any size is ingested with flat memory use and
+// before the download has finished.
 type RSSFeed struct {
-	Title       string    `xml:"channel>title"`
-	Description string    `xml:"channel>description"`
-	Link        string    `xml:"channel>link"`
-	Items       []RSSItem `xml:"channel>item"`
+	Title       string
+	Description string
+	Link        string
+
+	// Hubs and Self are the WebSub hub URLs and topic URL advertised by the
+	// feed, either in <atom:link> elements or in HTTP Link headers.
+	Hubs []string
+	Self string
+
+	decoder *xml.Decoder
+	body    io.Closer
+	err     error
+}
+
+type RSSLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
 }
 
 type RSSItem struct {
@@ -22,62 +45,206 @@ type RSSItem struct {
 	Description string `xml:"description"`
 	Link        string `xml:"link"`
 	PubDate     string `xml:"pubDate"`
+	// Content is the full content of the item from <content:encoded>.
+	Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
 }
 
-// fetchFeed fetches an RSS feed from the given URL and parses it into an RSSFeed
-// struct. It also unescapes HTML entities in the feed fields.
+// fetchFeed fetches an RSS feed from the given URL and returns it as an
+// RSSFeed that parses the response body as it is read. The caller must close
+// the returned feed.
 //
 // The function uses the given context to cancel the HTTP request if it
-// times out or is canceled.
+// times out or is canceled. The request is sent with the fetcher's shared
+// client, so the configured timeouts, proxy and User-Agent apply.
 //
-// If the HTTP request fails, the function returns an error. If the HTTP
-// request succeeds but the response body is not valid XML, the function
-// returns an error.
+// If the HTTP request fails, the function returns an error. Errors in the
+// body, such as invalid XML, are reported by the feed's Err method once its
+// items have been read.
 //
-// If the function succeeds, it returns a pointer to the parsed RSSFeed
-// struct.
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	// Create an HTTP request with context
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set User-Agent header
-	req.Header.Add("User-Agent", "gator")
-
+// Credentials and extra headers configured for the feed, if any, are applied
+// to the request.
+func (f *fetcher) fetchFeed(ctx context.Context, feedURL string, creds *feedCredentials) (*RSSFeed, error) {
 	// Execute the HTTP request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := f.get(ctx, feedURL, creds)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch feed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, errors.New("unexpected HTTP status: " + resp.Status)
 	}
 
-	// Read the response body
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read feed data: %w", err)
+	feed := newRSSFeed(resp.Body)
+	feed.body = resp.Body
+
+	// Hubs may also be advertised in HTTP Link headers
+	for _, link := range parseLinkHeaders(resp.Header.Values("Link")) {
+		feed.addLink(link)
 	}
 
-	// Parse the XML into the RSSFeed struct
-	var feed RSSFeed
-	if err := xml.Unmarshal(data, &feed); err != nil {
-		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	return feed, nil
+}
+
+// newRSSFeed returns an RSSFeed that parses the RSS document read from r. The
+// document is untrusted, so it is decoded within defaultXMLLimits.
+func newRSSFeed(r io.Reader) *RSSFeed {
+	return &RSSFeed{
+		decoder: newGuardedDecoder(r, defaultXMLLimits),
 	}
+}
 
-	// Decode escaped HTML entities in the feed fields
-	feed.Title = html.UnescapeString(feed.Title)
-	feed.Description = html.UnescapeString(feed.Description)
-	for i, item := range feed.Items {
-		feed.Items[i].Title = html.UnescapeString(item.Title)
-		feed.Items[i].Description = html.UnescapeString(item.Description)
+// Items returns an iterator over the items of the feed, decoding each one as
+// it is reached in the document. HTML entities in item titles, descriptions
+// and content are unescaped. The channel fields seen so far are available
+// while iterating, and all of them once the iteration is done. A feed can
+// only be iterated once; check Err afterwards.
+func (f *RSSFeed) Items() iter.Seq[RSSItem] {
+	return func(yield func(RSSItem) bool) {
+		inChannel := false
+		for {
+			tok, err := f.decoder.Token()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				f.err = fmt.Errorf("failed to parse feed: %w", err)
+				return
+			}
+
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "channel" {
+					inChannel = true
+					continue
+				}
+				if !inChannel {
+					continue
+				}
+
+				item, isItem, err := f.decodeChannelElement(t)
+				if err != nil {
+					f.err = fmt.Errorf("failed to parse feed: %w", err)
+					return
+				}
+				if isItem && !yield(item) {
+					return
+				}
+			case xml.EndElement:
+				if t.Name.Local == "channel" {
+					inChannel = false
+				}
+			}
+		}
 	}
+}
 
-	return &feed, nil
+// decodeChannelElement decodes a direct child of <channel>. Items are
+// returned; channel fields and WebSub links are stored on the feed; other
+// elements are skipped.
+func (f *RSSFeed) decodeChannelElement(start xml.StartElement) (RSSItem, bool, error) {
+	if start.Name.Space == atomNamespace {
+		if start.Name.Local == "link" {
+			var link RSSLink
+			if err := f.decoder.DecodeElement(&link, &start); err != nil {
+				return RSSItem{}, false, err
+			}
+			f.addLink(link)
+			return RSSItem{}, false, nil
+		}
+		return RSSItem{}, false, f.decoder.Skip()
+	}
+	if start.Name.Space != "" {
+		// Extension elements, such as <itunes:title>, are not used
+		return RSSItem{}, false, f.decoder.Skip()
+	}
+
+	var field *string
+	switch start.Name.Local {
+	case "item":
+		var item RSSItem
+		if err := f.decoder.DecodeElement(&item, &start); err != nil {
+			return RSSItem{}, false, err
+		}
+		// Decode escaped HTML entities in the item fields
+		item.Title = html.UnescapeString(item.Title)
+		item.Description = html.UnescapeString(item.Description)
+		item.Content = html.UnescapeString(item.Content)
+		return item, true, nil
+	case "title":
+		field = &f.Title
+	case "description":
+		field = &f.Description
+	case "link":
+		field = &f.Link
+	default:
+		return RSSItem{}, false, f.decoder.Skip()
+	}
+
+	var value string
+	if err := f.decoder.DecodeElement(&value, &start); err != nil {
+		return RSSItem{}, false, err
+	}
+	// Decode escaped HTML entities in the channel fields
+	*field = html.UnescapeString(value)
+	return RSSItem{}, false, nil
+}
+
+// Err returns the error that stopped the iteration over the feed's items, if
+// any.
+func (f *RSSFeed) Err() error {
+	return f.err
+}
+
+// Close closes the response body the feed is read from, if any.
+func (f *RSSFeed) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+// addLink records a hub or self link on the feed. Other relations are ignored.
+func (f *RSSFeed) addLink(link RSSLink) {
+	href := strings.TrimSpace(link.Href)
+	if href == "" {
+		return
+	}
+	for _, rel := range strings.Fields(strings.ToLower(link.Rel)) {
+		switch rel {
+		case "hub":
+			if !slices.Contains(f.Hubs, href) {
+				f.Hubs = append(f.Hubs, href)
+			}
+		case "self":
+			if f.Self == "" {
+				f.Self = href
+			}
+		}
+	}
+}
+
+// parseLinkHeaders parses HTTP Link header values of the form
+// `<https://hub.example.com/>; rel="hub"` into RSSLink values.
+func parseLinkHeaders(values []string) []RSSLink {
+	var links []RSSLink
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			segments := strings.Split(part, ";")
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			link := RSSLink{Href: strings.Trim(target, "<>")}
+			for _, param := range segments[1:] {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "rel") {
+					link.Rel = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
 }
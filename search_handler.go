@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/search"
+	"github.com/google/uuid"
+)
+
+// searchResultRecord is a post found by "search" as printed in structured
+// output formats. Matched words in the snippet are marked with "**".
+type searchResultRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Feed        string     `json:"feed"`
+	PublishedAt *time.Time `json:"published_at"`
+	Snippet     string     `json:"snippet"`
+}
+
+// searchHelp describes the query syntax of "search".
+const searchHelp = `The query matches words anywhere in a post's title, description or content:
+  postgres vacuum        both words
+  "full text search"     the exact phrase
+  vacuum -autovacuum     vacuum but not autovacuum
+  postgres or sqlite     either word
+
+Dates are YYYY-MM-DD, RFC 3339 timestamps, or ages like 30d or 2w.`
+
+// defaultSearchLimit is the number of results shown when --limit is not given.
+const defaultSearchLimit = 10
+
+// handlerSearch handles the "search" command, which searches the posts of the
+// feeds the current user follows. Results are ranked by relevance, with
+// title matches counting the most, and are printed with a snippet in which
+// the matched words are highlighted. Flags may appear anywhere among the
+// query words; a single leading "-" on a word negates it instead.
+func handlerSearch(s *state, cmd command, user database.User) error {
+	params := database.SearchPostsForUserParams{
+		UserID:     user.ID,
+		MaxResults: defaultSearchLimit,
+	}
+
+	if value, ok := cmd.stringFlag("feed"); ok {
+		feedURL, err := normalizeURL(value)
+		if err != nil {
+			return err
+		}
+		params.FeedUrl = sql.NullString{String: feedURL, Valid: true}
+	}
+	if value, ok := cmd.stringFlag("since"); ok {
+		since, _, err := parseDate(value)
+		if err != nil {
+			return err
+		}
+		params.Since = sql.NullTime{Time: since, Valid: true}
+	}
+	if value, ok := cmd.stringFlag("until"); ok {
+		until, dayOnly, err := parseDate(value)
+		if err != nil {
+			return err
+		}
+		// A day given on its own is included in the results
+		if dayOnly {
+			until = until.AddDate(0, 0, 1)
+		}
+		params.Until = sql.NullTime{Time: until, Valid: true}
+	}
+	if limit, ok := cmd.intFlag("limit"); ok {
+		if limit <= 0 {
+			return fmt.Errorf("invalid limit: %v", limit)
+		}
+		params.MaxResults = int32(limit)
+	}
+
+	params.Query = strings.Join(cmd.args, " ")
+	if search.Parse(params.Query).IsEmpty() {
+		return newUsageError("empty query")
+	}
+
+	results, err := s.db.SearchPostsForUser(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("failed to search posts: %w", err)
+	}
+	if s.output.structured() {
+		records := make([]searchResultRecord, 0, len(results))
+		for _, result := range results {
+			record := searchResultRecord{
+				ID:      result.ID,
+				Title:   result.Title,
+				URL:     result.Url,
+				Feed:    result.FeedName,
+				Snippet: cleanSnippet(result.Snippet, false),
+			}
+			if result.PublishedAt.Valid {
+				record.PublishedAt = &result.PublishedAt.Time
+			}
+			records = append(records, record)
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No posts match %q.\n", params.Query)
+		return nil
+	}
+
+	highlight := isTerminal(os.Stdout)
+	for i, result := range results {
+		fmt.Printf("%d. %s\n", i+1, result.Title)
+		published := "unknown date"
+		if result.PublishedAt.Valid {
+			published = result.PublishedAt.Time.Format("2006-01-02")
+		}
+		fmt.Printf("   %s, %s\n", result.FeedName, published)
+		fmt.Printf("   %s\n", result.Url)
+		fmt.Printf("   ID: %s\n", result.ID)
+		if snippet := cleanSnippet(result.Snippet, highlight); snippet != "" {
+			fmt.Printf("   %s\n", snippet)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// htmlTagPattern matches HTML tags left in snippets of post descriptions.
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanSnippet turns a snippet from the database into a single line of plain
+// text. Matched words are marked with "**"; on a terminal the markers are
+// replaced with bold text.
+func cleanSnippet(snippet string, highlight bool) string {
+	snippet = htmlTagPattern.ReplaceAllString(snippet, " ")
+	snippet = strings.Join(strings.Fields(html.UnescapeString(snippet)), " ")
+	if !highlight {
+		return snippet
+	}
+
+	parts := strings.Split(snippet, "**")
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			if i%2 == 1 {
+				b.WriteString("\033[1m")
+			} else {
+				b.WriteString("\033[0m")
+			}
+		}
+		b.WriteString(part)
+	}
+	if len(parts)%2 == 0 {
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
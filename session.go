@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// sessionDuration is how long a login lasts before the user has to log in
+// again.
+const sessionDuration = 30 * 24 * time.Hour
+
+// errNotLoggedIn is returned when the config file holds no valid session.
+var errNotLoggedIn = errors.New("no user is currently logged in")
+
+// hashSessionToken returns the hash under which a session token is stored in
+// the database, so that the tokens themselves only exist in config files.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession logs the user in by creating a new session and storing its
+// token in the config file. The session previously stored in the config file,
+// if any, is ended.
+func startSession(s *state, user database.User) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	expiresAt := now.Add(sessionDuration)
+
+	// End the previous session and clean up expired ones together with
+	// creating the new session
+	err := s.db.InTx(ctx, func(q database.Querier) error {
+		if s.cfg.SessionToken != "" {
+			if err := q.DeleteSession(ctx, hashSessionToken(s.cfg.SessionToken)); err != nil {
+				return fmt.Errorf("failed to end previous session: %w", err)
+			}
+		}
+		if err := q.DeleteExpiredSessions(ctx, now); err != nil {
+			return fmt.Errorf("failed to delete expired sessions: %w", err)
+		}
+		err := q.CreateSession(ctx, database.CreateSessionParams{
+			TokenHash: hashSessionToken(token),
+			UserID:    user.ID,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.cfg.SetSession(user.Name, token, expiresAt); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// sessionUser returns the user logged in with the session token from the
+// config file. It returns errNotLoggedIn if there is no token, and an error
+// asking the user to log in again if the session expired or was ended.
+func sessionUser(s *state) (database.User, error) {
+	if s.cfg.SessionToken == "" {
+		return database.User{}, errNotLoggedIn
+	}
+
+	now := time.Now()
+	if s.cfg.SessionExpiresAt != nil && !now.Before(*s.cfg.SessionExpiresAt) {
+		return database.User{}, errors.New("your session has expired, please log in again")
+	}
+
+	user, err := s.db.GetSessionUser(context.Background(), database.GetSessionUserParams{
+		TokenHash: hashSessionToken(s.cfg.SessionToken),
+		Now:       now,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errors.New("your session is no longer valid, please log in again")
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to fetch current user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,12 @@
+-- name: UpsertFeedCredentials :exec
+INSERT INTO feed_credentials (feed_id, created_at, updated_at, ciphertext)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    ciphertext = EXCLUDED.ciphertext;
+
+-- name: GetFeedCredentials :one
+SELECT * FROM feed_credentials WHERE feed_id = $1;
+
+-- name: DeleteFeedCredentials :exec
+DELETE FROM feed_credentials WHERE feed_id = $1;
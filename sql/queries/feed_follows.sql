@@ -15,6 +15,9 @@ JOIN users ON inserted.user_id = users.id
 JOIN feeds ON inserted.feed_id = feeds.id;
 
 
+-- name: GetFeedFollow :one
+SELECT * FROM feed_follows WHERE user_id = $1 AND feed_id = $2;
+
 -- name: GetFeedByUrl :one
 SELECT * FROM feeds WHERE url = $1;
 
@@ -23,10 +26,33 @@ SELECT
     feed_follows.id, 
     feed_follows.created_at, 
     feed_follows.updated_at, 
-    feeds.name AS feed_name, 
-    users.name AS user_name
+    coalesce(feed_follows.alias, feeds.name) AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    folders.name AS folder_name,
+    (
+        SELECT count(*)
+        FROM posts p
+        WHERE p.feed_id = feeds.id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_reads pr
+                WHERE pr.post_id = p.id AND pr.user_id = feed_follows.user_id
+            )
+    ) AS unread_count
 FROM feed_follows
 JOIN feeds ON feed_follows.feed_id = feeds.id
 JOIN users ON feed_follows.user_id = users.id
-WHERE feed_follows.user_id = $1
-ORDER BY feeds.name;
+LEFT JOIN folders ON feed_follows.folder_id = folders.id
+WHERE feed_follows.user_id = @user_id
+    AND (sqlc.narg('folder')::text IS NULL OR folders.name = sqlc.narg('folder'))
+ORDER BY folders.name NULLS FIRST, feed_name;
+
+-- name: SetFeedFollowAlias :execrows
+UPDATE feed_follows
+SET alias = @alias, updated_at = @updated_at
+WHERE user_id = @user_id
+    AND feed_id = (SELECT id FROM feeds WHERE url = @feed_url);
+
+-- name: CountFeedFollowers :one
+SELECT count(*) FROM feed_follows
+WHERE feed_id = $1;
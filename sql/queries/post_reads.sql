@@ -0,0 +1,23 @@
+-- name: MarkPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT ff.user_id, p.id, @read_at::timestamp
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE ff.user_id = @user_id
+    AND (sqlc.narg('post_url')::text IS NULL OR p.url = sqlc.narg('post_url'))
+    AND (sqlc.narg('feed_url')::text IS NULL OR f.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('before')::timestamp IS NULL OR coalesce(p.published_at, p.created_at) < sqlc.narg('before'))
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostsUnread :execrows
+DELETE FROM post_reads
+WHERE post_reads.user_id = @user_id
+    AND post_reads.post_id IN (
+        SELECT p.id
+        FROM posts p
+        JOIN feeds f ON p.feed_id = f.id
+        WHERE (sqlc.narg('post_url')::text IS NULL OR p.url = sqlc.narg('post_url'))
+            AND (sqlc.narg('feed_url')::text IS NULL OR f.url = sqlc.narg('feed_url'))
+            AND (sqlc.narg('before')::timestamp IS NULL OR coalesce(p.published_at, p.created_at) < sqlc.narg('before'))
+    );
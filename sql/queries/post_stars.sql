@@ -0,0 +1,43 @@
+-- name: StarPost :exec
+INSERT INTO post_stars (user_id, post_id, created_at, updated_at, note)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    note = coalesce(EXCLUDED.note, post_stars.note);
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2;
+
+-- name: AddPostStarTag :exec
+INSERT INTO post_star_tags (user_id, post_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeletePostStarTag :exec
+DELETE FROM post_star_tags WHERE user_id = $1 AND post_id = $2 AND tag = $3;
+
+-- name: GetStarredPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    coalesce(ff.alias, f.name) AS feed_name,
+    ps.note,
+    ps.created_at AS starred_at
+FROM post_stars ps
+JOIN posts p ON p.id = ps.post_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN feed_follows ff ON ff.feed_id = f.id AND ff.user_id = ps.user_id
+WHERE ps.user_id = @user_id
+    AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+        SELECT 1 FROM post_star_tags t
+        WHERE t.user_id = ps.user_id AND t.post_id = ps.post_id AND t.tag = sqlc.narg('tag')
+    ))
+ORDER BY ps.created_at DESC;
+
+-- name: GetStarTagsForUser :many
+SELECT post_id, tag FROM post_star_tags
+WHERE user_id = $1
+ORDER BY post_id, tag;
@@ -3,10 +3,96 @@ INSERT INTO posts (id, created_at, updated_at, title, url, description, publishe
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
 
 -- name: GetPostsForUser :many
-SELECT p.id, p.title, p.url, p.description, p.published_at, p.feed_id
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    p.feed_id,
+    pr.post_id IS NOT NULL AS read
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
-WHERE ff.user_id = $1
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = ff.user_id
+LEFT JOIN folders fo ON fo.id = ff.folder_id
+WHERE ff.user_id = @user_id
+    AND (NOT @unread_only::bool OR pr.post_id IS NULL)
+    AND (sqlc.narg('folder')::text IS NULL OR fo.name = sqlc.narg('folder'))
 ORDER BY p.published_at DESC
-LIMIT $2;
+LIMIT sqlc.arg('limit');
+
+-- name: CountPostsOfOtherFeeds :one
+-- Posts with one of the URLs that were saved from another feed. UpsertPosts
+-- leaves them alone.
+SELECT count(*) FROM posts
+WHERE url = ANY(@urls::text[]) AND feed_id <> @feed_id::uuid;
+
+-- name: UpsertPosts :many
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
+SELECT
+    t.id,
+    @now::timestamp,
+    @now::timestamp,
+    t.title,
+    t.url,
+    NULLIF(t.description, ''),
+    NULLIF(t.published_at, '0001-01-01 00:00:00'::timestamp),
+    @feed_id::uuid,
+    NULLIF(t.content, '')
+FROM unnest(
+    @ids::uuid[],
+    @titles::text[],
+    @urls::text[],
+    @descriptions::text[],
+    @published_ats::timestamp[],
+    @contents::text[]
+) AS t(id, title, url, description, published_at, content)
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = @feed_id::uuid AND pp.url = t.url)
+ON CONFLICT (url) DO UPDATE
+SET title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    content = EXCLUDED.content,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.feed_id = EXCLUDED.feed_id
+    AND (posts.title, posts.description, posts.published_at, posts.content)
+    IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.description, EXCLUDED.published_at, EXCLUDED.content)
+RETURNING (xmax = 0) AS inserted;
+
+-- name: SearchPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    p.feed_id,
+    coalesce(ff.alias, f.name) AS feed_name,
+    ts_rank_cd(p.search, q.query)::float8 AS rank,
+    ts_headline(
+        'english',
+        coalesce(p.description, '') || ' ' || coalesce(p.content, ''),
+        q.query,
+        'StartSel=**, StopSel=**, MaxWords=30, MinWords=10, MaxFragments=2'
+    ) AS snippet
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+CROSS JOIN websearch_to_tsquery('english', @query) AS q(query)
+WHERE ff.user_id = @user_id
+    AND p.search @@ q.query
+    AND (sqlc.narg('feed_url')::text IS NULL OR f.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('since')::timestamp IS NULL OR p.published_at >= sqlc.narg('since'))
+    AND (sqlc.narg('until')::timestamp IS NULL OR p.published_at < sqlc.narg('until'))
+ORDER BY rank DESC, p.published_at DESC NULLS LAST
+LIMIT @max_results;
+
+-- name: GetPostByID :one
+SELECT id, title, url, feed_id FROM posts WHERE id = $1;
+
+-- name: GetPostByUrl :one
+SELECT id, title, url, feed_id FROM posts WHERE url = $1;
+
+-- name: DeleteUnstarredPosts :exec
+-- Deletes all posts that nobody starred.
+DELETE FROM posts WHERE id NOT IN (SELECT post_id FROM post_stars);
@@ -0,0 +1,35 @@
+-- name: GetPostsToPrune :many
+-- Posts are dated by publication, or by when they were first saved if the
+-- feed did not date them. Starred posts are never pruned, but count towards
+-- the number of posts kept. A NULL bound matches no posts.
+SELECT p.id
+FROM (
+    SELECT
+        posts.id,
+        coalesce(posts.published_at, posts.created_at) AS post_date,
+        row_number() OVER (
+            ORDER BY coalesce(posts.published_at, posts.created_at) DESC, posts.id
+        ) AS position
+    FROM posts
+    WHERE posts.feed_id = @feed_id
+) p
+WHERE NOT EXISTS (SELECT 1 FROM post_stars ps WHERE ps.post_id = p.id)
+    AND (
+        p.post_date < sqlc.narg('before')::timestamp
+        OR p.position > sqlc.narg('keep')::int
+    )
+ORDER BY p.post_date;
+
+-- name: RememberPrunedPosts :exec
+INSERT INTO pruned_posts (url, feed_id, pruned_at)
+SELECT url, feed_id, @pruned_at::timestamp
+FROM posts
+WHERE id = ANY(@ids::uuid[])
+ON CONFLICT (feed_id, url) DO NOTHING;
+
+-- name: DeletePosts :execrows
+DELETE FROM posts WHERE id = ANY(@ids::uuid[]);
+
+-- name: ForgetPrunedPosts :exec
+-- Forgets all pruned posts, so fetching their feeds saves them again.
+DELETE FROM pruned_posts;
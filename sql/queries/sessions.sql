@@ -0,0 +1,23 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetSessionUser :one
+-- Returns the user of a session that has not expired yet.
+SELECT users.* FROM sessions
+JOIN users ON sessions.user_id = users.id
+WHERE sessions.token_hash = @token_hash AND sessions.expires_at > @now;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions
+WHERE expires_at <= $1;
+
+-- name: DeleteUserSessions :exec
+-- Ends all sessions of a user except the one with the given token hash, if
+-- any.
+DELETE FROM sessions
+WHERE user_id = @user_id AND token_hash <> @keep_token_hash;
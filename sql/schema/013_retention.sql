@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN retention_max_age_days INTEGER;
+ALTER TABLE feeds ADD COLUMN retention_max_posts INTEGER;
+
+-- URLs of pruned posts, so that fetching the feed again does not bring the
+-- posts back. They are remembered per feed, so that pruning a post in one
+-- feed does not keep another feed from saving a post with the same URL.
+CREATE TABLE pruned_posts (
+    url TEXT NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    pruned_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (feed_id, url)
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
+ALTER TABLE feeds DROP COLUMN retention_max_posts;
+ALTER TABLE feeds DROP COLUMN retention_max_age_days;
@@ -0,0 +1,51 @@
+-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING
+    id,
+    created_at,
+    updated_at,
+    (SELECT name FROM users WHERE users.id = feed_follows.user_id) AS user_name,
+    (SELECT name FROM feeds WHERE feeds.id = feed_follows.feed_id) AS feed_name;
+
+-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id, folder_id, alias FROM feed_follows WHERE user_id = $1 AND feed_id = $2;
+
+-- name: GetFeedByUrl :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts FROM feeds WHERE url = $1;
+
+-- name: GetFeedFollowsForUser :many
+SELECT
+    feed_follows.id,
+    feed_follows.created_at,
+    feed_follows.updated_at,
+    coalesce(feed_follows.alias, feeds.name) AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    folders.name AS folder_name,
+    (
+        SELECT count(*)
+        FROM posts p
+        WHERE p.feed_id = feeds.id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_reads pr
+                WHERE pr.post_id = p.id AND pr.user_id = feed_follows.user_id
+            )
+    ) AS unread_count
+FROM feed_follows
+JOIN feeds ON feed_follows.feed_id = feeds.id
+JOIN users ON feed_follows.user_id = users.id
+LEFT JOIN folders ON feed_follows.folder_id = folders.id
+WHERE feed_follows.user_id = $1
+    AND ($2 IS NULL OR folders.name = $2)
+ORDER BY folders.name NULLS FIRST, feed_name;
+
+-- name: SetFeedFollowAlias :execrows
+UPDATE feed_follows
+SET alias = $1, updated_at = $2
+WHERE user_id = $3
+    AND feed_id = (SELECT id FROM feeds WHERE url = $4);
+
+-- name: CountFeedFollowers :one
+SELECT count(*) FROM feed_follows
+WHERE feed_id = $1;
@@ -0,0 +1,58 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts;
+
+-- name: GetAllFeedsWithUsers :many
+SELECT
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    ff.alias
+FROM feeds
+JOIN users ON feeds.user_id = users.id
+LEFT JOIN feed_follows ff ON ff.feed_id = feeds.id
+    AND ff.user_id = (SELECT u.id FROM users u WHERE u.name = $1)
+ORDER BY users.name, feeds.name;
+
+-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts FROM feeds WHERE id = $1;
+
+-- name: GetFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts
+FROM feeds ORDER BY name;
+
+-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_days = $1, retention_max_posts = $2, updated_at = $3
+WHERE id = $4;
+
+-- name: TransferFeeds :execrows
+-- Gives each feed added by a user to the other user who has followed it the
+-- longest. Feeds that no one else follows are left unchanged.
+UPDATE feeds
+SET user_id = (
+    SELECT ff.user_id FROM feed_follows ff
+    WHERE ff.feed_id = feeds.id AND ff.user_id <> $1
+    ORDER BY ff.created_at, ff.id
+    LIMIT 1
+), updated_at = $2
+WHERE feeds.user_id = $1
+    AND EXISTS (
+        SELECT 1 FROM feed_follows ff
+        WHERE ff.feed_id = feeds.id AND ff.user_id <> $1
+    );
+
+-- name: UpdateFeed :exec
+-- Changing the URL also clears last_fetched_at, so the feed is fetched from
+-- its new URL next.
+UPDATE feeds
+SET name = $1,
+    last_fetched_at = CASE WHEN url = $2 THEN last_fetched_at ELSE NULL END,
+    url = $2,
+    updated_at = $3
+WHERE id = $4;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
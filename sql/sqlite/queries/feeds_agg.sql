@@ -0,0 +1,14 @@
+-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET last_fetched_at = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: GetNextFeedToFetch :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, retention_max_age_days, retention_max_posts
+FROM feeds
+WHERE last_fetched_at IS NULL
+   OR last_fetched_at = (
+       SELECT MIN(last_fetched_at)
+       FROM feeds
+   )
+LIMIT 1;
@@ -0,0 +1,42 @@
+-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, updated_at, user_id, name)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, user_id, name;
+
+-- name: DeleteFolder :execrows
+DELETE FROM folders WHERE user_id = $1 AND name = $2;
+
+-- name: GetFolderByName :one
+SELECT id, created_at, updated_at, user_id, name FROM folders WHERE user_id = $1 AND name = $2;
+
+-- name: GetFoldersForUser :many
+SELECT
+    fo.id,
+    fo.name,
+    (SELECT count(*) FROM feed_follows ff WHERE ff.folder_id = fo.id) AS feed_count,
+    (
+        SELECT count(*)
+        FROM posts p
+        JOIN feed_follows ff ON ff.feed_id = p.feed_id
+        WHERE ff.folder_id = fo.id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_reads pr
+                WHERE pr.post_id = p.id AND pr.user_id = fo.user_id
+            )
+    ) AS unread_count
+FROM folders fo
+WHERE fo.user_id = $1
+ORDER BY fo.name;
+
+-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+SET folder_id = $1, updated_at = $2
+WHERE user_id = $3
+    AND feed_id = (SELECT id FROM feeds WHERE url = $4);
+
+-- name: RemoveFeedFollowFromFolder :execrows
+UPDATE feed_follows
+SET folder_id = NULL, updated_at = $1
+WHERE user_id = $2
+    AND folder_id = $3
+    AND feed_id = (SELECT id FROM feeds WHERE url = $4);
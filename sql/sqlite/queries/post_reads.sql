@@ -0,0 +1,23 @@
+-- name: MarkPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT ff.user_id, p.id, $1
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE ff.user_id = $2
+    AND ($3 IS NULL OR p.url = $3)
+    AND ($4 IS NULL OR f.url = $4)
+    AND ($5 IS NULL OR coalesce(p.published_at, p.created_at) < $5)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostsUnread :execrows
+DELETE FROM post_reads
+WHERE post_reads.user_id = $1
+    AND post_reads.post_id IN (
+        SELECT p.id
+        FROM posts p
+        JOIN feeds f ON p.feed_id = f.id
+        WHERE ($2 IS NULL OR p.url = $2)
+            AND ($3 IS NULL OR f.url = $3)
+            AND ($4 IS NULL OR coalesce(p.published_at, p.created_at) < $4)
+    );
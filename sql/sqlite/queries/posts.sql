@@ -0,0 +1,92 @@
+-- name: CreatePost :exec
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    p.feed_id,
+    pr.post_id IS NOT NULL AS read
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = ff.user_id
+LEFT JOIN folders fo ON fo.id = ff.folder_id
+WHERE ff.user_id = $1
+    AND (NOT $2 OR pr.post_id IS NULL)
+    AND ($3 IS NULL OR fo.name = $3)
+ORDER BY p.published_at DESC NULLS FIRST
+LIMIT $4;
+
+-- name: SearchPostsForUser :many
+-- websearch_to_fts5 is registered by internal/sqlite; bm25 is negated so that
+-- higher ranks are better, as in PostgreSQL.
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    p.feed_id,
+    coalesce(ff.alias, f.name) AS feed_name,
+    -bm25(posts_search, 10.0, 4.0, 1.0) AS rank,
+    snippet(posts_search, -1, '**', '**', '...', 20) AS snippet
+FROM posts_search
+JOIN posts p ON p.seq = posts_search.rowid
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE posts_search MATCH websearch_to_fts5($1)
+    AND ff.user_id = $2
+    AND ($3 IS NULL OR f.url = $3)
+    AND ($4 IS NULL OR p.published_at >= $4)
+    AND ($5 IS NULL OR p.published_at < $5)
+ORDER BY rank DESC, p.published_at DESC NULLS LAST
+LIMIT $6;
+
+-- name: CountPostsOfOtherFeeds :one
+SELECT count(*) FROM posts
+WHERE url IN (SELECT value FROM json_each($1)) AND feed_id <> $2;
+
+-- name: UpsertPosts :many
+-- Array parameters are passed as JSON arrays; zero timestamps arrive as null.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
+SELECT
+    ids.value,
+    $1,
+    $1,
+    titles.value,
+    urls.value,
+    NULLIF(descriptions.value, ''),
+    published_ats.value,
+    $2,
+    NULLIF(contents.value, '')
+FROM json_each($3) AS ids
+JOIN json_each($4) AS titles ON titles.key = ids.key
+JOIN json_each($5) AS urls ON urls.key = ids.key
+JOIN json_each($6) AS descriptions ON descriptions.key = ids.key
+JOIN json_each($7) AS published_ats ON published_ats.key = ids.key
+JOIN json_each($8) AS contents ON contents.key = ids.key
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = $2 AND pp.url = urls.value)
+ON CONFLICT (url) DO UPDATE
+SET title = excluded.title,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    content = excluded.content,
+    updated_at = excluded.updated_at
+WHERE posts.feed_id = excluded.feed_id
+    AND (posts.title, posts.description, posts.published_at, posts.content)
+    IS NOT (excluded.title, excluded.description, excluded.published_at, excluded.content)
+RETURNING created_at = $1 AS inserted;
+
+-- name: GetPostByID :one
+SELECT id, title, url, feed_id FROM posts WHERE id = $1;
+
+-- name: GetPostByUrl :one
+SELECT id, title, url, feed_id FROM posts WHERE url = $1;
+
+-- name: DeleteUnstarredPosts :exec
+-- Deletes all posts that nobody starred.
+DELETE FROM posts WHERE id NOT IN (SELECT post_id FROM post_stars);
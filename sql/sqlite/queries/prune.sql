@@ -0,0 +1,33 @@
+-- name: GetPostsToPrune :many
+SELECT p.id
+FROM (
+    SELECT
+        posts.id,
+        coalesce(posts.published_at, posts.created_at) AS post_date,
+        row_number() OVER (
+            ORDER BY coalesce(posts.published_at, posts.created_at) DESC, posts.id
+        ) AS position
+    FROM posts
+    WHERE posts.feed_id = $1
+) p
+WHERE NOT EXISTS (SELECT 1 FROM post_stars ps WHERE ps.post_id = p.id)
+    AND (
+        p.post_date < $2
+        OR p.position > $3
+    )
+ORDER BY p.post_date;
+
+-- name: RememberPrunedPosts :exec
+-- Array parameters are passed as JSON arrays.
+INSERT INTO pruned_posts (url, feed_id, pruned_at)
+SELECT url, feed_id, $1
+FROM posts
+WHERE id IN (SELECT value FROM json_each($2))
+ON CONFLICT (feed_id, url) DO NOTHING;
+
+-- name: DeletePosts :execrows
+DELETE FROM posts WHERE id IN (SELECT value FROM json_each($1));
+
+-- name: ForgetPrunedPosts :exec
+-- Forgets all pruned posts, so fetching their feeds saves them again.
+DELETE FROM pruned_posts;
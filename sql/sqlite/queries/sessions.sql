@@ -0,0 +1,23 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetSessionUser :one
+-- Returns the user of a session that has not expired yet.
+SELECT users.id, users.created_at, users.updated_at, users.name, users.password_hash, users.is_admin FROM sessions
+JOIN users ON sessions.user_id = users.id
+WHERE sessions.token_hash = $1 AND sessions.expires_at > $2;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions
+WHERE expires_at <= $1;
+
+-- name: DeleteUserSessions :exec
+-- Ends all sessions of a user except the one with the given token hash, if
+-- any.
+DELETE FROM sessions
+WHERE user_id = $1 AND token_hash <> $2;
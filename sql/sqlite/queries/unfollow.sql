@@ -0,0 +1,4 @@
+-- name: DeleteFeedFollowByUserAndURL :exec
+DELETE FROM feed_follows
+WHERE feed_follows.user_id = $1
+    AND feed_follows.feed_id IN (SELECT id FROM feeds WHERE url = $2);
@@ -0,0 +1,45 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name, password_hash, is_admin)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id, created_at, updated_at, name, password_hash, is_admin;
+
+-- name: GetUser :one
+SELECT id, created_at, updated_at, name, password_hash, is_admin FROM users
+WHERE name = $1 LIMIT 1;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: GetUsers :many
+SELECT id, created_at, updated_at, name, password_hash, is_admin FROM users
+ORDER BY name;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: SetUserAdmin :execrows
+UPDATE users
+SET is_admin = $1, updated_at = $2
+WHERE name = $3;
+
+-- name: CountAdmins :one
+SELECT count(*) FROM users
+WHERE is_admin;
+
+-- name: RenameUser :exec
+UPDATE users
+SET name = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
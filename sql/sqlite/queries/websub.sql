@@ -0,0 +1,30 @@
+-- name: UpsertWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = excluded.updated_at,
+    hub_url = excluded.hub_url,
+    topic_url = excluded.topic_url,
+    secret = excluded.secret,
+    lease_expires_at = NULL
+RETURNING id, created_at, updated_at, feed_id, hub_url, topic_url, secret, lease_expires_at;
+
+-- name: GetWebSubSubscription :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, lease_expires_at FROM websub_subscriptions WHERE id = $1;
+
+-- name: GetWebSubSubscriptionByFeedID :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, lease_expires_at FROM websub_subscriptions WHERE feed_id = $1;
+
+-- name: SetWebSubLease :exec
+UPDATE websub_subscriptions
+SET lease_expires_at = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, lease_expires_at FROM websub_subscriptions
+WHERE lease_expires_at IS NOT NULL
+  AND lease_expires_at < $1
+ORDER BY lease_expires_at;
+
+-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions WHERE id = $1;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- seq is an alias of the rowid, so the full-text index of posts_search can
+-- refer to posts by it: unlike an implicit rowid, VACUUM never renumbers it.
+CREATE TABLE posts (
+    seq INTEGER PRIMARY KEY,
+    id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE posts;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE websub_subscriptions (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id TEXT NOT NULL UNIQUE REFERENCES feeds(id) ON DELETE CASCADE,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret BLOB NOT NULL,
+    lease_expires_at TIMESTAMP NULL
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
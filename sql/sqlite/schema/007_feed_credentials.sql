@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE feed_credentials (
+    feed_id TEXT PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    ciphertext BLOB NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feed_credentials;
@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content TEXT;
+
+-- The index refers to posts by their seq column, which VACUUM keeps.
+CREATE VIRTUAL TABLE posts_search USING fts5(
+    title,
+    description,
+    content,
+    content = 'posts',
+    content_rowid = 'seq',
+    tokenize = 'porter unicode61'
+);
+
+INSERT INTO posts_search (posts_search) VALUES ('rebuild');
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_search (rowid, title, description, content)
+    VALUES (new.seq, new.title, new.description, new.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_search (posts_search, rowid, title, description, content)
+    VALUES ('delete', old.seq, old.title, old.description, old.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_update AFTER UPDATE ON posts BEGIN
+    INSERT INTO posts_search (posts_search, rowid, title, description, content)
+    VALUES ('delete', old.seq, old.title, old.description, old.content);
+    INSERT INTO posts_search (rowid, title, description, content)
+    VALUES (new.seq, new.title, new.description, new.content);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER posts_search_update;
+DROP TRIGGER posts_search_delete;
+DROP TRIGGER posts_search_insert;
+DROP TABLE posts_search;
+ALTER TABLE posts DROP COLUMN content;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE post_stars (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    note TEXT,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX post_stars_post_id_idx ON post_stars (post_id);
+
+CREATE TABLE post_star_tags (
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (user_id, post_id, tag),
+    FOREIGN KEY (user_id, post_id) REFERENCES post_stars(user_id, post_id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE post_star_tags;
+DROP TABLE post_stars;
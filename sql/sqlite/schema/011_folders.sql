@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE folders (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE(user_id, name)
+);
+
+ALTER TABLE feed_follows ADD COLUMN folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL;
+
+CREATE INDEX feed_follows_folder_id_idx ON feed_follows (folder_id);
+
+-- +goose Down
+DROP INDEX feed_follows_folder_id_idx;
+ALTER TABLE feed_follows DROP COLUMN folder_id;
+DROP TABLE folders;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- The first user becomes an admin, so existing installs keep one
+UPDATE users SET is_admin = true
+WHERE id = (SELECT id FROM users ORDER BY created_at LIMIT 1);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN is_admin;
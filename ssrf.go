@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// errBlockedAddress is returned when a feed URL points at an address that the
+// aggregator must not fetch from.
+var errBlockedAddress = errors.New("address is not allowed")
+
+// blockedPrefixes lists ranges that are never fetched from unless allowlisted,
+// in addition to the loopback, private, link-local, multicast and unspecified
+// ranges recognized by net/netip. They cover cloud metadata endpoints, carrier
+// grade NAT and other reserved ranges.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// addressGuard rejects connections to internal addresses so that user-supplied
+// feed URLs cannot be used to reach the aggregator's own network. It checks
+// the resolved address of every connection, which covers DNS rebinding and
+// each hop of a redirect chain. Requests sent through a proxy only ever
+// connect to the proxy, so their target hosts are resolved and checked before
+// the request is sent instead.
+type addressGuard struct {
+	allowed []netip.Prefix
+
+	// proxy returns the proxy a request is sent through, like
+	// http.Transport.Proxy. It is nil if requests are never proxied.
+	proxy func(*http.Request) (*url.URL, error)
+	// lookup resolves the target hosts of proxied requests.
+	lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// newAddressGuard builds a guard that permits the given allowlist of networks
+// (CIDRs or single IP addresses) despite being internal. Requests for which
+// proxy returns a proxy have their target hosts resolved and checked.
+func newAddressGuard(allowedNetworks []string, proxy func(*http.Request) (*url.URL, error)) (*addressGuard, error) {
+	g := &addressGuard{proxy: proxy, lookup: net.DefaultResolver.LookupNetIP}
+	for _, network := range allowedNetworks {
+		if err := g.allow(network); err != nil {
+			return nil, fmt.Errorf("invalid allowed_networks entry %q: %w", network, err)
+		}
+	}
+	return g, nil
+}
+
+// allow adds a CIDR or single IP address to the allowlist.
+func (g *addressGuard) allow(network string) error {
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return err
+		}
+		g.allowed = append(g.allowed, prefix.Masked())
+		return nil
+	}
+
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+	g.allowed = append(g.allowed, netip.PrefixFrom(addr, addr.BitLen()))
+	return nil
+}
+
+// checkAddr returns an error wrapping errBlockedAddress if the address is
+// internal and not allowlisted.
+func (g *addressGuard) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %s is an internal address", errBlockedAddress, addr)
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is in reserved range %s", errBlockedAddress, addr, prefix)
+		}
+	}
+	return nil
+}
+
+// control is used as net.Dialer.Control to check the resolved address of
+// every outgoing connection before it is made.
+func (g *addressGuard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBlockedAddress, address)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBlockedAddress, address)
+	}
+	return g.checkAddr(addr)
+}
+
+// checkURL rejects URLs that must not be fetched before any connection is
+// made: schemes other than http and https, and hosts that are literal
+// internal addresses or "localhost". Hosts that need resolving are checked
+// at dial time by control, except when the request goes through a proxy:
+// then the host is resolved here and every address it resolves to is checked.
+func (g *addressGuard) checkURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not supported, use http or https", errBlockedAddress, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: URL has no host", errBlockedAddress)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return g.checkAddr(addr)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return g.checkAddr(netip.AddrFrom4([4]byte{127, 0, 0, 1}))
+	}
+	if !g.proxied(u) {
+		return nil
+	}
+
+	addrs, err := g.lookup(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s: %v", errBlockedAddress, host, err)
+	}
+	for _, addr := range addrs {
+		if err := g.checkAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxied reports whether a request for the URL is sent through a proxy. A
+// URL the proxy function fails on counts as proxied, so that it is checked.
+func (g *addressGuard) proxied(u *url.URL) bool {
+	if g.proxy == nil {
+		return false
+	}
+	proxyURL, err := g.proxy(&http.Request{URL: u})
+	return err != nil || proxyURL != nil
+}
+
+// maxRedirects is the number of redirects followed before a fetch fails.
+const maxRedirects = 10
+
+// credentialHeadersKey is the context key under which fetcher.get stores the
+// names of the headers carrying a feed's credentials.
+type credentialHeadersKey struct{}
+
+// checkRedirect is used as http.Client.CheckRedirect to check every hop of a
+// redirect chain before following it. The feed's credential headers are only
+// sent to the host the feed was requested from: net/http strips the standard
+// ones from some cross-host hops only, and custom headers never.
+func (g *addressGuard) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		names, _ := req.Context().Value(credentialHeadersKey{}).([]string)
+		for _, name := range names {
+			req.Header.Del(name)
+		}
+	}
+	return g.checkURL(req.Context(), req.URL)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	config "github.com/Fepozopo/gator/internal/config"
+)
+
+// fakeLookup resolves hosts from a fixed table.
+func fakeLookup(hosts map[string][]string) func(context.Context, string, string) ([]netip.Addr, error) {
+	return func(_ context.Context, _, host string) ([]netip.Addr, error) {
+		values, ok := hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("no such host %s", host)
+		}
+		addrs := make([]netip.Addr, len(values))
+		for i, value := range values {
+			addrs[i] = netip.MustParseAddr(value)
+		}
+		return addrs, nil
+	}
+}
+
+func TestFetchThroughProxyChecksTargetAddresses(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		if r.URL.Host == "redirect.example.com" {
+			http.Redirect(w, r, "http://internal.example.com/feed.xml", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer proxy.Close()
+
+	// The proxy listens on loopback, which is not allowlisted
+	f, err := newFetcher(config.HTTPConfig{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("newFetcher: %v", err)
+	}
+	f.guard.lookup = fakeLookup(map[string][]string{
+		"feeds.example.com":    {"93.184.216.34"},
+		"redirect.example.com": {"93.184.216.35"},
+		"metadata.example.com": {"169.254.169.254"},
+		"mixed.example.com":    {"93.184.216.34", "10.0.0.7"},
+		"internal.example.com": {"10.1.2.3"},
+	})
+
+	resp, err := f.get(context.Background(), "http://feeds.example.com/feed.xml", nil)
+	if err != nil {
+		t.Fatalf("fetching a public host through the proxy: %v", err)
+	}
+	resp.Body.Close()
+
+	blocked := []string{
+		"http://metadata.example.com/latest/meta-data/",
+		"http://mixed.example.com/feed.xml",
+		"http://unresolvable.example.com/feed.xml",
+		"http://redirect.example.com/feed.xml",
+		proxy.URL + "/feed.xml", // the proxy's own address
+	}
+	for _, rawURL := range blocked {
+		resp, err := f.get(context.Background(), rawURL, nil)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("fetched %s, want it refused", rawURL)
+			continue
+		}
+		if !errors.Is(err, errBlockedAddress) {
+			t.Errorf("fetching %s: got %v, want errBlockedAddress", rawURL, err)
+		}
+	}
+
+	// Only the public host and the redirecting one reached the proxy
+	want := []string{"http://feeds.example.com/feed.xml", "http://redirect.example.com/feed.xml"}
+	if fmt.Sprint(proxied) != fmt.Sprint(want) {
+		t.Errorf("proxy received %v, want %v", proxied, want)
+	}
+}
+
+func TestCheckURLWithoutProxyLeavesResolvingToDial(t *testing.T) {
+	g, err := newAddressGuard(nil, nil)
+	if err != nil {
+		t.Fatalf("newAddressGuard: %v", err)
+	}
+	g.lookup = func(context.Context, string, string) ([]netip.Addr, error) {
+		t.Fatal("resolved a host that is checked when dialing")
+		return nil, nil
+	}
+
+	for rawURL, wantBlocked := range map[string]bool{
+		"http://feeds.example.com/feed.xml": false,
+		"http://10.0.0.1/feed.xml":          true,
+		"http://localhost:8080/feed.xml":    true,
+		"file:///etc/passwd":                true,
+	} {
+		u, _ := url.Parse(rawURL)
+		err := g.checkURL(context.Background(), u)
+		if blocked := errors.Is(err, errBlockedAddress); blocked != wantBlocked {
+			t.Errorf("checkURL(%s) = %v, want blocked %v", rawURL, err, wantBlocked)
+		}
+	}
+}
+
+func TestRedirectToOtherHostDropsCredentials(t *testing.T) {
+	received := make(map[string]http.Header)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received["other"] = r.Header.Clone()
+		io.WriteString(w, "ok")
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/feed.xml", http.StatusFound)
+		case "/elsewhere":
+			http.Redirect(w, r, other.URL+"/feed.xml", http.StatusFound)
+		default:
+			received["origin"] = r.Header.Clone()
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer origin.Close()
+
+	f, err := newFetcher(config.HTTPConfig{AllowedNetworks: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("newFetcher: %v", err)
+	}
+	creds := &feedCredentials{
+		BearerToken: "token",
+		Cookie:      "session=abc",
+		Headers:     map[string]string{"X-Api-Key": "key"},
+	}
+
+	for _, path := range []string{"/moved", "/elsewhere"} {
+		resp, err := f.get(context.Background(), origin.URL+path, creds)
+		if err != nil {
+			t.Fatalf("fetching %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	// Redirects within the host keep the credentials, others drop them
+	for _, name := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+		if received["origin"].Get(name) == "" {
+			t.Errorf("%s was not sent to the feed's host", name)
+		}
+		if value := received["other"].Get(name); value != "" {
+			t.Errorf("%s: %q was sent to another host", name, value)
+		}
+	}
+	if received["other"].Get("User-Agent") != f.userAgent {
+		t.Error("the User-Agent was not sent to the other host")
+	}
+}
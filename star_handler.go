@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// findPost looks up a post by the ID or URL given on the command line.
+func findPost(s *state, ref string) (database.GetPostByIDRow, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		post, err := s.db.GetPostByID(context.Background(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return post, fmt.Errorf("no post with ID %s", ref)
+		}
+		return post, err
+	}
+
+	postURL, err := normalizeURL(ref)
+	if err != nil {
+		return database.GetPostByIDRow{}, err
+	}
+	post, err := s.db.GetPostByUrl(context.Background(), postURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.GetPostByIDRow{}, fmt.Errorf("no post with URL %s", postURL)
+	}
+	return database.GetPostByIDRow(post), err
+}
+
+// parseTags splits a --tag value into tags. Several tags may be given at once,
+// separated by commas. Tags are lowercased.
+func parseTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// handlerStar handles the "star" command, which saves a post to the current
+// user's starred posts, optionally with a note and tags. Starring a post that
+// is already starred replaces its note, if one is given, and adds or removes
+// tags. Starred posts are kept when old posts are pruned.
+func handlerStar(s *state, cmd command, user database.User) error {
+	var note sql.NullString
+	if value, ok := cmd.stringFlag("note"); ok {
+		note = sql.NullString{String: value, Valid: true}
+	}
+	var addTags, removeTags []string
+	for _, value := range cmd.stringsFlag("tag") {
+		addTags = append(addTags, parseTags(value)...)
+	}
+	for _, value := range cmd.stringsFlag("untag") {
+		removeTags = append(removeTags, parseTags(value)...)
+	}
+
+	post, err := findPost(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	err = s.db.InTx(context.Background(), func(q database.Querier) error {
+		now := time.Now()
+		err := q.StarPost(context.Background(), database.StarPostParams{
+			UserID:    user.ID,
+			PostID:    post.ID,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Note:      note,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to star post: %w", err)
+		}
+
+		for _, tag := range addTags {
+			err := q.AddPostStarTag(context.Background(), database.AddPostStarTagParams{
+				UserID: user.ID,
+				PostID: post.ID,
+				Tag:    tag,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to add tag %q: %w", tag, err)
+			}
+		}
+		for _, tag := range removeTags {
+			err := q.DeletePostStarTag(context.Background(), database.DeletePostStarTagParams{
+				UserID: user.ID,
+				PostID: post.ID,
+				Tag:    tag,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to remove tag %q: %w", tag, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Starred '%s'\n", post.Title)
+	return nil
+}
+
+// handlerUnstar handles the "unstar" command, which removes a post from the
+// current user's starred posts, along with its note and tags.
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	post, err := findPost(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	removed, err := s.db.UnstarPost(context.Background(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unstar post: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("'%s' is not starred", post.Title)
+	}
+
+	fmt.Printf("Unstarred '%s'\n", post.Title)
+	return nil
+}
+
+// starredPost is a starred post with its tags, in the form it is exported.
+type starredPost struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Feed        string     `json:"feed"`
+	PublishedAt *time.Time `json:"published_at"`
+	StarredAt   time.Time  `json:"starred_at"`
+	Note        string     `json:"note"`
+	Tags        []string   `json:"tags"`
+}
+
+// handlerStarred handles the "starred" command, which lists the current
+// user's starred posts, most recently starred first. With --tag, only posts
+// with that tag are listed. With --export markdown or --export json, the
+// list is written in that format, e.g. to keep a reading list elsewhere.
+func handlerStarred(s *state, cmd command, user database.User) error {
+	var tag sql.NullString
+	if value, ok := cmd.stringFlag("tag"); ok {
+		tag = sql.NullString{String: strings.ToLower(strings.TrimSpace(value)), Valid: true}
+	}
+	export, _ := cmd.stringFlag("export")
+
+	rows, err := s.db.GetStarredPostsForUser(context.Background(), database.GetStarredPostsForUserParams{
+		UserID: user.ID,
+		Tag:    tag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get starred posts: %w", err)
+	}
+	tagRows, err := s.db.GetStarTagsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	tagsByPost := make(map[uuid.UUID][]string)
+	for _, row := range tagRows {
+		tagsByPost[row.PostID] = append(tagsByPost[row.PostID], row.Tag)
+	}
+
+	posts := make([]starredPost, 0, len(rows))
+	for _, row := range rows {
+		post := starredPost{
+			ID:        row.ID,
+			Title:     row.Title,
+			URL:       row.Url,
+			Feed:      row.FeedName,
+			StarredAt: row.StarredAt,
+			Note:      row.Note.String,
+			Tags:      tagsByPost[row.ID],
+		}
+		if post.Tags == nil {
+			post.Tags = []string{}
+		}
+		if row.PublishedAt.Valid {
+			post.PublishedAt = &row.PublishedAt.Time
+		}
+		posts = append(posts, post)
+	}
+
+	if export == "" && s.output.structured() {
+		return writeRecords(os.Stdout, s.output, posts)
+	}
+
+	switch export {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(posts)
+	case "markdown":
+		printStarredMarkdown(posts)
+		return nil
+	}
+
+	if len(posts) == 0 {
+		fmt.Print("No starred posts.\n")
+		return nil
+	}
+	for _, post := range posts {
+		fmt.Printf("* %s (%s)\n", post.Title, post.Feed)
+		fmt.Printf("  %s\n", post.URL)
+		fmt.Printf("  ID: %s\n", post.ID)
+		if len(post.Tags) > 0 {
+			fmt.Printf("  Tags: %s\n", strings.Join(post.Tags, ", "))
+		}
+		if post.Note != "" {
+			fmt.Printf("  Note: %s\n", post.Note)
+		}
+	}
+	return nil
+}
+
+// printStarredMarkdown prints starred posts as a Markdown reading list.
+func printStarredMarkdown(posts []starredPost) {
+	fmt.Print("# Starred posts\n")
+	for _, post := range posts {
+		fmt.Printf("\n## [%s](%s)\n\n", post.Title, post.URL)
+
+		details := post.Feed
+		if post.PublishedAt != nil {
+			details += ", " + post.PublishedAt.Format("2006-01-02")
+		}
+		if len(post.Tags) > 0 {
+			details += " · " + "`" + strings.Join(post.Tags, "` `") + "`"
+		}
+		fmt.Printf("%s\n", details)
+
+		if post.Note != "" {
+			fmt.Printf("\n> %s\n", strings.ReplaceAll(post.Note, "\n", "\n> "))
+		}
+	}
+}
@@ -2,11 +2,24 @@ package main
 
 import (
 	config "github.com/Fepozopo/gator/internal/config"
-	database "github.com/Fepozopo/gator/internal/database"
 )
 
 // state holds application-level state.
 type state struct {
-	db  *database.Queries
+	db  storage
 	cfg *config.Config
+
+	// conn is the database connection behind db, used to run migrations. It
+	// is nil when db is not backed by a database.
+	conn *dbConn
+
+	// output is the format listing commands print in.
+	output outputFormat
+
+	// fetcher is the shared HTTP client used to fetch feeds.
+	fetcher *fetcher
+
+	// websub is the WebSub callback listener, set only while "agg" runs with
+	// WebSub enabled in the config.
+	websub *webSubListener
 }
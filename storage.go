@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	database "github.com/Fepozopo/gator/internal/database"
+)
+
+// storage is everything the commands read and write: users, feeds, follows,
+// posts and the data kept for fetching feeds. It is implemented by the
+// generated queries over PostgreSQL or SQLite, and by memstore.Store, which
+// lets handlers run without a database.
+type storage interface {
+	database.Querier
+
+	// InTx runs fn with queries whose writes are committed together if fn
+	// returns nil, and discarded otherwise.
+	InTx(ctx context.Context, fn func(q database.Querier) error) error
+}
+
+// sqlStorage is the storage backed by a database connection.
+type sqlStorage struct {
+	*database.Queries
+	conn *dbConn
+}
+
+// newSQLStorage returns the storage for a database connection.
+func newSQLStorage(conn *dbConn) *sqlStorage {
+	return &sqlStorage{Queries: conn.queries(), conn: conn}
+}
+
+// InTx runs fn inside a database transaction.
+func (st *sqlStorage) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := st.conn.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(st.conn.withTx(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
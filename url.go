@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams lists query parameters that carry no meaning for the linked
+// resource and are removed by normalizeURL. Parameters starting with "utm_" are
+// removed as well.
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+}
+
+// normalizeURL returns a canonical form of the given absolute URL so that the
+// same resource always maps to the same string. The scheme and host are
+// lowercased, default ports and fragments are removed, tracking parameters
+// (utm_*, fbclid, ...) are dropped, and the remaining query parameters are
+// sorted by key.
+//
+// An error is returned if the URL cannot be parsed or is not absolute.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid URL %q: must be absolute", rawURL)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	// Lowercase the host and drop the port if it is the scheme default
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	// Drop tracking parameters and sort the rest
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = encodeSortedQuery(query)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// encodeSortedQuery encodes the query values sorted by key, keeping the
+// original order of repeated values for the same key.
+func encodeSortedQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+	return b.String()
+}
+
+// resolveURL resolves a possibly relative link against the given base URLs,
+// using the first base that is an absolute URL. Absolute links are returned
+// unchanged.
+func resolveURL(link string, bases ...string) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return "", fmt.Errorf("invalid link %q: %w", link, err)
+	}
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+
+	for _, base := range bases {
+		baseURL, err := url.Parse(strings.TrimSpace(base))
+		if err != nil || !baseURL.IsAbs() {
+			continue
+		}
+		return baseURL.ResolveReference(ref).String(), nil
+	}
+	return "", fmt.Errorf("cannot resolve relative link %q: no absolute base URL", link)
+}
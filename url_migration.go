@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// normalizeURLsVersion is the version of the migration that normalizes the
+// URLs stored before normalizeURL was applied to feed and post URLs.
+const normalizeURLsVersion = 16
+
+// normalizeURLsMigration returns the Go migration that rewrites feed, post
+// and pruned post URLs into the form normalizeURL gives them, so that feeds
+// added earlier can be found by the URLs users type. A URL whose normalized
+// form is already taken by another row, or that cannot be parsed, is left as
+// it is. The migration cannot be undone, but rolling it back is harmless.
+func normalizeURLsMigration() *goose.Migration {
+	m := goose.NewGoMigration(normalizeURLsVersion,
+		&goose.GoFunc{RunTx: normalizeStoredURLs},
+		&goose.GoFunc{RunTx: func(context.Context, *sql.Tx) error { return nil }},
+	)
+	m.Source = "016_normalize_urls.go"
+	return m
+}
+
+// normalizeStoredURLs normalizes the URLs of the feeds, posts and
+// pruned_posts tables. The queries are written to run on PostgreSQL and
+// SQLite alike.
+func normalizeStoredURLs(ctx context.Context, tx *sql.Tx) error {
+	// Feed and post URLs are unique across the table, and pruned post URLs
+	// within a feed. A row is identified by its scope and URL.
+	tables := []struct {
+		name  string
+		scope string
+	}{
+		{"feeds", "''"},
+		{"posts", "''"},
+		{"pruned_posts", "feed_id"},
+	}
+
+	for _, table := range tables {
+		type row struct{ scope, url string }
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s, url FROM %s", table.scope, table.name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table.name, err)
+		}
+		var stored []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.scope, &r.url); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read %s: %w", table.name, err)
+			}
+			stored = append(stored, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read %s: %w", table.name, err)
+		}
+
+		for _, r := range stored {
+			normalized, err := normalizeURL(r.url)
+			if err != nil || normalized == r.url {
+				continue
+			}
+
+			// Keep the URL if another row already has its normalized form
+			var taken int
+			err = tx.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT count(*) FROM %s WHERE %s = $1 AND url = $2", table.name, table.scope),
+				r.scope, normalized,
+			).Scan(&taken)
+			if err != nil {
+				return fmt.Errorf("failed to check %s: %w", table.name, err)
+			}
+			if taken > 0 {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx,
+				fmt.Sprintf("UPDATE %s SET url = $1 WHERE %s = $2 AND url = $3", table.name, table.scope),
+				normalized, r.scope, r.url,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to normalize %s: %w", table.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lowercases scheme and host", "HTTPS://Example.COM/Feed", "https://example.com/Feed"},
+		{"drops default http port", "http://example.com:80/feed", "http://example.com/feed"},
+		{"drops default https port", "https://example.com:443/feed", "https://example.com/feed"},
+		{"keeps other ports", "https://example.com:8443/feed", "https://example.com:8443/feed"},
+		{"keeps port of other scheme", "http://example.com:443/feed", "http://example.com:443/feed"},
+		{"adds root path", "https://example.com", "https://example.com/"},
+		{"drops fragment", "https://example.com/post#comments", "https://example.com/post"},
+		{"sorts query", "https://example.com/?b=2&a=1&c=3", "https://example.com/?a=1&b=2&c=3"},
+		{"keeps order of repeated keys", "https://example.com/?tag=z&id=1&tag=a", "https://example.com/?id=1&tag=z&tag=a"},
+		{"drops tracking parameters", "https://example.com/?utm_source=x&id=1&UTM_Medium=y&fbclid=f&gclid=g", "https://example.com/?id=1"},
+		{"drops empty query", "https://example.com/feed?", "https://example.com/feed"},
+		{"rewrites bare key", "https://example.com/?foo", "https://example.com/?foo="},
+		{"trims whitespace", "  https://example.com/feed\n", "https://example.com/feed"},
+		{"brackets IPv6 host", "http://[::1]:80/feed", "http://[::1]/feed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeURL(tt.in)
+			if err != nil {
+				t.Fatalf("normalizeURL(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeURLRejectsRelativeURLs(t *testing.T) {
+	for _, in := range []string{"", "/feed.xml", "example.com/feed", "https://", "http://%zz"} {
+		if got, err := normalizeURL(in); err == nil {
+			t.Errorf("normalizeURL(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		link  string
+		bases []string
+		want  string
+	}{
+		{"absolute link", "https://other.example/post", []string{"https://example.com/"}, "https://other.example/post"},
+		{"relative link", "posts/1", []string{"https://example.com/blog/"}, "https://example.com/blog/posts/1"},
+		{"root relative link", "/posts/1", []string{"https://example.com/blog/"}, "https://example.com/posts/1"},
+		{"skips relative bases", "posts/1", []string{"/blog/", "https://example.com/"}, "https://example.com/posts/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveURL(tt.link, tt.bases...)
+			if err != nil {
+				t.Fatalf("resolveURL(%q): %v", tt.link, err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveURL(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolveURL("posts/1", "/blog/"); err == nil {
+		t.Error("resolved a relative link without an absolute base")
+	}
+}
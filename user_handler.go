@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+// handlerUser handles the "user" command, which renames or deletes a user
+// account, or sets its password. Users can manage their own account, and
+// admins any account.
+func handlerUser(s *state, cmd command, user database.User) error {
+	action, args := cmd.args[0], cmd.args[1:]
+	yes := cmd.boolFlag("yes")
+	if yes && action != "delete" {
+		return newUsageError("--yes only applies to 'user delete'")
+	}
+
+	switch {
+	case action == "rename" && len(args) == 1:
+		return renameUser(s, user, user.Name, args[0])
+	case action == "rename" && len(args) == 2:
+		return renameUser(s, user, args[0], args[1])
+	case action == "delete" && len(args) == 0:
+		return deleteUser(s, user, user.Name, yes)
+	case action == "delete" && len(args) == 1:
+		return deleteUser(s, user, args[0], yes)
+	case action == "set-password" && len(args) == 0:
+		return setPassword(s, user, user.Name)
+	case action == "set-password" && len(args) == 1:
+		return setPassword(s, user, args[0])
+	case action == "rename" || action == "delete" || action == "set-password":
+		return newUsageError("wrong number of arguments for 'user %s'", action)
+	default:
+		return newUsageError("unknown action %q", action)
+	}
+}
+
+// findManagedUser looks up the user with the given name, making sure the
+// current user may manage their account.
+func findManagedUser(s *state, current database.User, name string) (database.User, error) {
+	if name == current.Name {
+		return current, nil
+	}
+	if !current.IsAdmin {
+		return database.User{}, errors.New("only admins can manage other users")
+	}
+	target, err := s.db.GetUser(context.Background(), name)
+	if err != nil {
+		return database.User{}, fmt.Errorf("user '%s' does not exist", name)
+	}
+	return target, nil
+}
+
+// renameUser renames a user. The user's feeds, follows and stars are kept.
+func renameUser(s *state, current database.User, name, newName string) error {
+	target, err := findManagedUser(s, current, name)
+	if err != nil {
+		return err
+	}
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return errors.New("new name cannot be empty")
+	}
+
+	err = s.db.RenameUser(context.Background(), database.RenameUserParams{
+		Name:      newName,
+		UpdatedAt: time.Now(),
+		ID:        target.ID,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("a user with the name '%s' already exists", newName)
+		}
+		return fmt.Errorf("failed to rename user: %w", err)
+	}
+
+	if target.ID == current.ID {
+		if err := s.cfg.SetUser(newName); err != nil {
+			return fmt.Errorf("failed to set current user: %w", err)
+		}
+	}
+
+	fmt.Printf("Renamed '%s' to '%s'\n", target.Name, newName)
+	return nil
+}
+
+// setPassword sets the password of a user. Users changing their own password
+// have to enter the current one first; admins can set the password of any
+// other user, such as one created before passwords existed. The user's other
+// sessions are ended with the change, so only the session that changed their
+// own password stays logged in.
+func setPassword(s *state, current database.User, name string) error {
+	target, err := findManagedUser(s, current, name)
+	if err != nil {
+		return err
+	}
+
+	if target.ID == current.ID && target.PasswordHash.Valid {
+		password, err := readPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		if !checkPassword(target.PasswordHash.String, password) {
+			return errors.New("invalid password")
+		}
+	}
+
+	fmt.Printf("Choose a new password for '%s'.\n", target.Name)
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	keep := ""
+	if target.ID == current.ID {
+		keep = hashSessionToken(s.cfg.SessionToken)
+	}
+
+	ctx := context.Background()
+	err = s.db.InTx(ctx, func(q database.Querier) error {
+		err := q.SetUserPassword(ctx, database.SetUserPasswordParams{
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+			UpdatedAt:    time.Now(),
+			ID:           target.ID,
+		})
+		if err != nil {
+			return err
+		}
+		return q.DeleteUserSessions(ctx, database.DeleteUserSessionsParams{
+			UserID:        target.ID,
+			KeepTokenHash: keep,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	fmt.Printf("Password set for '%s'\n", target.Name)
+	return nil
+}
+
+// deleteUser deletes a user with their follows, folders, read states and
+// stars. Feeds the user added that other users follow are given to the user
+// who has followed them the longest, so the other followers keep them; feeds
+// no one else follows are deleted with their posts. The last admin cannot be
+// deleted while other users remain.
+func deleteUser(s *state, current database.User, name string, yes bool) error {
+	target, err := findManagedUser(s, current, name)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if target.IsAdmin {
+		admins, err := s.db.CountAdmins(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count admins: %w", err)
+		}
+		users, err := s.db.GetUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch users: %w", err)
+		}
+		if admins <= 1 && len(users) > 1 {
+			return fmt.Errorf("'%s' is the last admin; grant admin to another user first", target.Name)
+		}
+	}
+
+	if !yes {
+		question := fmt.Sprintf("This deletes user '%s' with their follows, folders and stars, and the feeds they added that nobody else follows. Continue?", target.Name)
+		ok, err := confirm(question)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+
+	var transferred, deleted int
+	err = s.db.InTx(ctx, func(q database.Querier) error {
+		n, err := q.TransferFeeds(ctx, database.TransferFeedsParams{
+			UserID:    target.ID,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to transfer feeds: %w", err)
+		}
+		transferred = int(n)
+
+		feeds, err := q.GetFeeds(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch feeds: %w", err)
+		}
+		for _, feed := range feeds {
+			if feed.UserID == target.ID {
+				deleted++
+			}
+		}
+
+		if err := q.DeleteUser(ctx, target.ID); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if target.ID == current.ID {
+		if err := s.cfg.ClearSession(); err != nil {
+			return fmt.Errorf("failed to clear session: %w", err)
+		}
+	}
+
+	fmt.Printf("Deleted user '%s': %d feed(s) given to other followers, %d feed(s) deleted\n", target.Name, transferred, deleted)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+)
+
+func TestSetPassword(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	admin := makeTestAdmin(t, s, createTestUser(t, s, "admin"))
+	admin = setTestPassword(t, s, admin, "admin password")
+	alice := setTestPassword(t, s, createTestUser(t, s, "alice"), "alice password")
+	legacy := createTestUser(t, s, "legacy")
+
+	run := func(user string, args []string, input ...string) error {
+		t.Helper()
+		current, err := s.db.GetUser(ctx, user)
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		setTestInput(t, input...)
+		captureStdout(t, func() {
+			err = handlerUser(s, command{name: "user", args: append([]string{"set-password"}, args...)}, current)
+		})
+		return err
+	}
+	password := func(name string) string {
+		t.Helper()
+		user, err := s.db.GetUser(ctx, name)
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		return user.PasswordHash.String
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		args    []string
+		input   []string
+		target  string
+		wantErr bool
+	}{
+		{"own password", alice.Name, nil, []string{"alice password", "new alice password"}, alice.Name, false},
+		{"wrong current password", alice.Name, nil, []string{"guess", "new alice password"}, alice.Name, true},
+		{"too short", alice.Name, nil, []string{"new alice password", "short"}, alice.Name, true},
+		{"other user as non-admin", alice.Name, []string{legacy.Name}, []string{"taken over"}, legacy.Name, true},
+		{"other user as admin", admin.Name, []string{legacy.Name}, []string{"legacy password"}, legacy.Name, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := password(tt.target)
+			err := run(tt.user, tt.args, tt.input...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if changed := password(tt.target) != before; changed == tt.wantErr {
+				t.Errorf("password changed: %v, want %v", changed, !tt.wantErr)
+			}
+		})
+	}
+
+	if !checkPassword(password(legacy.Name), "legacy password") {
+		t.Error("the password set by the admin does not match")
+	}
+}
+
+func TestSetPasswordEndsOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestState(t)
+	admin := setTestPassword(t, s, makeTestAdmin(t, s, createTestUser(t, s, "admin")), "admin password")
+	alice := setTestPassword(t, s, createTestUser(t, s, "alice"), "alice password")
+	valid := func(token string) bool {
+		_, err := s.db.GetSessionUser(ctx, database.GetSessionUserParams{
+			TokenHash: hashSessionToken(token),
+			Now:       time.Now(),
+		})
+		return err == nil
+	}
+
+	// Alice is logged in here and on another machine
+	setTestInput(t, "alice password", "new alice password")
+	if err := startSession(s, alice); err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	here := s.cfg.SessionToken
+	err := s.db.CreateSession(ctx, database.CreateSessionParams{
+		TokenHash: hashSessionToken("elsewhere"),
+		UserID:    alice.ID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// Changing her own password keeps only the current session
+	captureStdout(t, func() {
+		err = handlerUser(s, command{name: "user", args: []string{"set-password"}}, alice)
+	})
+	if err != nil {
+		t.Fatalf("changing her own password: %v", err)
+	}
+	if !valid(here) || valid("elsewhere") {
+		t.Errorf("after changing her own password: current session valid %v, other valid %v; want only the current one", valid(here), valid("elsewhere"))
+	}
+
+	// An admin setting her password ends all of them
+	setTestInput(t, "reset by admin")
+	captureStdout(t, func() {
+		err = handlerUser(s, command{name: "user", args: []string{"set-password", "alice"}}, admin)
+	})
+	if err != nil {
+		t.Fatalf("setting the password as an admin: %v", err)
+	}
+	if valid(here) {
+		t.Error("the session survived an admin setting the password")
+	}
+}
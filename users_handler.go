@@ -3,10 +3,21 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 )
 
+// userRecord is a user as printed by "users" in structured output formats.
+type userRecord struct {
+	Name      string    `json:"name"`
+	Admin     bool      `json:"admin"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // handlerUsers handles the "users" command, which lists all users in the database.
-// The list will show the currently logged-in user with "(current)" appended to their name.
+// The list will show the currently logged-in user with "(current)" appended to their name,
+// and admins with "(admin)".
 func handlerUsers(s *state, cmd command) error {
 	// Fetch all users from the database
 	users, err := s.db.GetUsers((context.Background()))
@@ -14,16 +25,32 @@ func handlerUsers(s *state, cmd command) error {
 		return fmt.Errorf("failed to fetch users: %w", err)
 	}
 
-	// Get the currently logged-in user from the config
-	currentUser := s.cfg.CurrentUserName
+	// Get the currently logged-in user from the session, if any
+	currentUser, _ := sessionUser(s)
+
+	if s.output.structured() {
+		records := make([]userRecord, 0, len(users))
+		for _, user := range users {
+			records = append(records, userRecord{
+				Name:      user.Name,
+				Admin:     user.IsAdmin,
+				Current:   user.ID == currentUser.ID,
+				CreatedAt: user.CreatedAt,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
 
 	// Print the list of users
 	for _, user := range users {
-		if user.Name == currentUser {
-			fmt.Printf("* %s (current)\n", user.Name)
-		} else {
-			fmt.Printf("* %s\n", user.Name)
+		line := "* " + user.Name
+		if user.IsAdmin {
+			line += " (admin)"
+		}
+		if user.ID == currentUser.ID {
+			line += " (current)"
 		}
+		fmt.Println(line)
 	}
 
 	return nil
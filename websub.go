@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/secrets"
+	"github.com/google/uuid"
+)
+
+const (
+	// webSubLeaseSeconds is the lease duration requested from hubs.
+	webSubLeaseSeconds = 10 * 24 * 60 * 60
+	// webSubRetryAfter is how long a subscription may stay unverified before
+	// the subscription request is sent again.
+	webSubRetryAfter = time.Hour
+	// webSubMaxBodySize caps the size of content pushed by a hub.
+	webSubMaxBodySize = 10 << 20
+)
+
+// webSubListener receives WebSub (PubSubHubbub) callbacks from hubs. It
+// subscribes to the hubs advertised by fetched feeds, answers intent
+// verification requests, and saves pushed content as posts.
+type webSubListener struct {
+	s           *state
+	callbackURL string
+	renewBefore time.Duration
+	server      *http.Server
+}
+
+// startWebSubListener starts the WebSub callback listener configured in the
+// state's config. It returns nil without an error if WebSub is not enabled.
+// Subscriptions are renewed once their lease expires within renewBefore.
+func startWebSubListener(s *state, renewBefore time.Duration) (*webSubListener, error) {
+	if s.cfg.WebSubListenAddr == "" {
+		return nil, nil
+	}
+
+	callbackURL, err := url.Parse(s.cfg.WebSubCallbackURL)
+	if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+		return nil, fmt.Errorf("websub_callback_url must be an absolute http(s) URL, got %q", s.cfg.WebSubCallbackURL)
+	}
+
+	l := &webSubListener{
+		s:           s,
+		callbackURL: strings.TrimRight(callbackURL.String(), "/"),
+		renewBefore: renewBefore,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/websub/{id}", l.handleCallback)
+	l.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ln, err := net.Listen("tcp", s.cfg.WebSubListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.cfg.WebSubListenAddr, err)
+	}
+	go func() {
+		if err := l.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("\nWebSub listener stopped: %v\n", err)
+		}
+	}()
+
+	return l, nil
+}
+
+// Close stops the callback listener.
+func (l *webSubListener) Close() error {
+	return l.server.Close()
+}
+
+// callbackFor returns the callback URL the hub should use for a subscription.
+func (l *webSubListener) callbackFor(sub database.WebsubSubscription) string {
+	return l.callbackURL + "/websub/" + sub.ID.String()
+}
+
+// subscribeIfNeeded subscribes to the first hub advertised by a fetched feed,
+// unless an active or recently requested subscription to the same hub and
+// topic already exists.
+func (l *webSubListener) subscribeIfNeeded(ctx context.Context, feed database.Feed, rssFeed *RSSFeed) error {
+	if len(rssFeed.Hubs) == 0 {
+		return nil
+	}
+
+	hubURL, err := resolveURL(rssFeed.Hubs[0], feed.Url)
+	if err != nil {
+		return err
+	}
+	topicURL := feed.Url
+	if rssFeed.Self != "" {
+		topicURL, err = resolveURL(rssFeed.Self, feed.Url)
+		if err != nil {
+			return err
+		}
+	}
+
+	sub, err := l.s.db.GetWebSubSubscriptionByFeedID(ctx, feed.ID)
+	if err == nil {
+		unchanged := sub.HubUrl == hubURL && sub.TopicUrl == topicURL
+		active := sub.LeaseExpiresAt.Valid && sub.LeaseExpiresAt.Time.After(time.Now())
+		pending := !sub.LeaseExpiresAt.Valid && time.Since(sub.UpdatedAt) < webSubRetryAfter
+		if unchanged && (active || pending) {
+			return nil
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	secret, err := newWebSubSecret()
+	if err != nil {
+		return err
+	}
+	if err := ensureCredentialsKey(l.s); err != nil {
+		return err
+	}
+	encrypted, err := secrets.Encrypt(l.s.cfg.CredentialsKey, []byte(secret), feed.ID[:])
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	now := time.Now()
+	sub, err = l.s.db.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		FeedID:    feed.ID,
+		HubUrl:    hubURL,
+		TopicUrl:  topicURL,
+		Secret:    encrypted,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	return l.subscribe(ctx, sub)
+}
+
+// subscribe sends a subscription request for the given subscription to its
+// hub. The hub confirms the subscription asynchronously through the callback.
+func (l *webSubListener) subscribe(ctx context.Context, sub database.WebsubSubscription) error {
+	secret, err := l.secretOf(sub)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {sub.TopicUrl},
+		"hub.callback":      {l.callbackFor(sub)},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(webSubLeaseSeconds)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.HubUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create subscription request: %w", err)
+	}
+	if err := l.s.fetcher.guard.checkURL(ctx, req.URL); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", l.s.fetcher.userAgent)
+
+	resp, err := l.s.fetcher.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send subscription request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub rejected subscription: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	fmt.Printf("\nRequested WebSub subscription for %s at %s\n", redactURL(sub.TopicUrl), redactURL(sub.HubUrl))
+	return nil
+}
+
+// renewExpiring renews all subscriptions whose lease expires soon. Failures
+// are reported per subscription and do not stop the remaining renewals.
+func (l *webSubListener) renewExpiring(ctx context.Context) error {
+	subs, err := l.s.db.GetWebSubSubscriptionsToRenew(ctx, sql.NullTime{
+		Time:  time.Now().Add(l.renewBefore),
+		Valid: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions to renew: %w", err)
+	}
+
+	for _, sub := range subs {
+		if err := l.subscribe(ctx, sub); err != nil {
+			fmt.Printf("\nFailed to renew subscription for %s: %v\n", redactURL(sub.TopicUrl), err)
+		}
+	}
+	return nil
+}
+
+// handleCallback handles requests from hubs to a subscription's callback URL:
+// GET requests verify subscription intent and POST requests deliver content.
+func (l *webSubListener) handleCallback(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		l.verifyIntent(w, r, id)
+	case http.MethodPost:
+		l.receiveContent(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifyIntent answers a hub's verification of intent. Subscriptions are
+// confirmed by echoing the challenge only if the topic matches the pending
+// subscription; denied subscriptions are removed.
+func (l *webSubListener) verifyIntent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+
+	sub, err := l.s.db.GetWebSubSubscription(r.Context(), id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		// Confirm unsubscribing from subscriptions we no longer know about
+		if mode == "unsubscribe" {
+			io.WriteString(w, query.Get("hub.challenge"))
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	if query.Get("hub.topic") != sub.TopicUrl {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch mode {
+	case "subscribe":
+		challenge := query.Get("hub.challenge")
+		if challenge == "" {
+			http.Error(w, "missing hub.challenge", http.StatusBadRequest)
+			return
+		}
+		leaseSeconds, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+		if err != nil || leaseSeconds <= 0 {
+			leaseSeconds = webSubLeaseSeconds
+		}
+
+		now := time.Now()
+		err = l.s.db.SetWebSubLease(r.Context(), database.SetWebSubLeaseParams{
+			LeaseExpiresAt: sql.NullTime{
+				Time:  now.Add(time.Duration(leaseSeconds) * time.Second),
+				Valid: true,
+			},
+			UpdatedAt: now,
+			ID:        sub.ID,
+		})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Printf("\nWebSub subscription for %s verified for %s\n", redactURL(sub.TopicUrl), time.Duration(leaseSeconds)*time.Second)
+		io.WriteString(w, challenge)
+	case "denied":
+		if err := l.s.db.DeleteWebSubSubscription(r.Context(), sub.ID); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		fmt.Printf("\nWebSub subscription for %s denied: %s\n", redactURL(sub.TopicUrl), query.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+	case "unsubscribe":
+		// We never unsubscribe from a subscription we still hold
+		http.NotFound(w, r)
+	default:
+		http.Error(w, "invalid hub.mode", http.StatusBadRequest)
+	}
+}
+
+// receiveContent handles content pushed by a hub. Content with a missing or
+// invalid signature is acknowledged but ignored, as the WebSub spec requires.
+// Valid content is parsed and saved through the same path as polled feeds.
+func (l *webSubListener) receiveContent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, webSubMaxBodySize+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > webSubMaxBodySize {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sub, err := l.s.db.GetWebSubSubscription(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown subscription", http.StatusGone)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	secret, err := l.secretOf(sub)
+	if err != nil {
+		fmt.Printf("\nIgnoring WebSub content for %s: %v\n", redactURL(sub.TopicUrl), err)
+		return
+	}
+	if !validWebSubSignature(secret, r.Header.Get("X-Hub-Signature"), body) {
+		fmt.Printf("\nIgnoring WebSub content for %s: invalid signature\n", redactURL(sub.TopicUrl))
+		return
+	}
+
+	feed, err := l.s.db.GetFeedByID(r.Context(), sub.FeedID)
+	if err != nil {
+		fmt.Printf("\nFailed to get feed for WebSub content: %v\n", err)
+		return
+	}
+
+	stats, err := savePosts(l.s, feed, newRSSFeed(bytes.NewReader(body)))
+	if err != nil {
+		fmt.Printf("\nFailed to save WebSub content for %s: %v\n", redactURL(feed.Url), err)
+		return
+	}
+	fmt.Printf("\nReceived WebSub content for %s: %s\n", redactURL(feed.Url), stats)
+}
+
+// secretOf returns the decrypted secret of a subscription. Secrets are
+// encrypted with the credentials key, bound to the subscription's feed.
+func (l *webSubListener) secretOf(sub database.WebsubSubscription) (string, error) {
+	secret, err := secrets.Decrypt(l.s.cfg.CredentialsKey, sub.Secret, sub.FeedID[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// validWebSubSignature reports whether the X-Hub-Signature header value
+// ("<method>=<hex digest>") is a valid HMAC of the body under the secret.
+func validWebSubSignature(secret, header string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// newWebSubSecret returns a random secret for signing pushed content.
+func newWebSubSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fepozopo/gator/internal/database"
+	"github.com/Fepozopo/gator/internal/secrets"
+)
+
+// testHub is a stand-in for a WebSub hub. It records the subscription
+// requests it receives, and plays the hub's part of verification and content
+// delivery by calling the listener's callback handler.
+type testHub struct {
+	server   *httptest.Server
+	requests chan url.Values
+}
+
+// newTestHub starts a hub that accepts every subscription request.
+func newTestHub(t *testing.T) *testHub {
+	t.Helper()
+
+	hub := &testHub{requests: make(chan url.Values, 10)}
+	hub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hub.requests <- r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(hub.server.Close)
+	return hub
+}
+
+// nextRequest returns the next subscription request the hub received.
+func (hub *testHub) nextRequest(t *testing.T) url.Values {
+	t.Helper()
+
+	select {
+	case form := <-hub.requests:
+		return form
+	default:
+		t.Fatal("hub received no subscription request")
+		return nil
+	}
+}
+
+// callback sends a request to the listener's callback URL from a
+// subscription request, as the hub would.
+func (hub *testHub) callback(l *webSubListener, method, callbackURL string, query url.Values, body string, header http.Header) *httptest.ResponseRecorder {
+	target, _ := url.Parse(callbackURL)
+	target.RawQuery = query.Encode()
+	req := httptest.NewRequest(method, target.String(), strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.SetPathValue("id", target.Path[strings.LastIndex(target.Path, "/")+1:])
+
+	rec := httptest.NewRecorder()
+	l.handleCallback(rec, req)
+	return rec
+}
+
+// verify confirms a subscription request with a challenge, and returns the
+// listener's response.
+func (hub *testHub) verify(l *webSubListener, form url.Values, topic, challenge string) *httptest.ResponseRecorder {
+	return hub.callback(l, http.MethodGet, form.Get("hub.callback"), url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.challenge":     {challenge},
+		"hub.lease_seconds": {"864000"},
+	}, "", nil)
+}
+
+// push delivers content signed with the given secret.
+func (hub *testHub) push(l *webSubListener, form url.Values, secret, body string) *httptest.ResponseRecorder {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	header := http.Header{"X-Hub-Signature": {"sha256=" + hex.EncodeToString(mac.Sum(nil))}}
+	return hub.callback(l, http.MethodPost, form.Get("hub.callback"), nil, body, header)
+}
+
+// newTestWebSub returns a listener over a fresh state, with a feed whose
+// fetched document advertises the hub.
+func newTestWebSub(t *testing.T, hub *testHub) (*webSubListener, database.Feed, *RSSFeed) {
+	t.Helper()
+
+	s := newTestState(t)
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	s.cfg.CredentialsKey = key
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Blog", "http://blog.example.com/feed.xml")
+	l := &webSubListener{s: s, callbackURL: "http://gator.example.com", renewBefore: time.Hour}
+	return l, feed, &RSSFeed{Hubs: []string{hub.server.URL}}
+}
+
+func TestWebSubSubscribeVerifyAndPush(t *testing.T) {
+	ctx := context.Background()
+	hub := newTestHub(t)
+	l, feed, rssFeed := newTestWebSub(t, hub)
+
+	if err := l.subscribeIfNeeded(ctx, feed, rssFeed); err != nil {
+		t.Fatalf("subscribeIfNeeded: %v", err)
+	}
+	form := hub.nextRequest(t)
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != feed.Url || form.Get("hub.secret") == "" {
+		t.Fatalf("unexpected subscription request: %v", form)
+	}
+
+	// The challenge is only echoed for the subscribed topic
+	if rec := hub.verify(l, form, "http://other.example.com/feed.xml", "nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("verifying another topic: got status %d, want 404", rec.Code)
+	}
+	rec := hub.verify(l, form, feed.Url, "challenge-123")
+	if rec.Code != http.StatusOK || rec.Body.String() != "challenge-123" {
+		t.Fatalf("verify: got %d %q, want the challenge echoed", rec.Code, rec.Body.String())
+	}
+	sub, err := l.s.db.GetWebSubSubscriptionByFeedID(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("GetWebSubSubscriptionByFeedID: %v", err)
+	}
+	if !sub.LeaseExpiresAt.Valid {
+		t.Fatal("verified subscription has no lease")
+	}
+
+	// Content signed with another secret is acknowledged but not saved
+	forged := testRSS([2]string{"Forged", "http://blog.example.com/forged"})
+	if rec := hub.push(l, form, "wrong secret", forged); rec.Code != http.StatusAccepted {
+		t.Errorf("push with invalid signature: got status %d, want 202", rec.Code)
+	}
+	if _, err := l.s.db.GetPostByUrl(ctx, "http://blog.example.com/forged"); err == nil {
+		t.Error("content with an invalid signature was saved")
+	}
+
+	signed := testRSS([2]string{"Pushed", "http://blog.example.com/pushed"})
+	if rec := hub.push(l, form, form.Get("hub.secret"), signed); rec.Code != http.StatusAccepted {
+		t.Errorf("push with valid signature: got status %d, want 202", rec.Code)
+	}
+	if _, err := l.s.db.GetPostByUrl(ctx, "http://blog.example.com/pushed"); err != nil {
+		t.Errorf("content with a valid signature was not saved: %v", err)
+	}
+}
+
+func TestWebSubResubscribeReplacesSecret(t *testing.T) {
+	ctx := context.Background()
+	hub := newTestHub(t)
+	l, feed, rssFeed := newTestWebSub(t, hub)
+
+	if err := l.subscribeIfNeeded(ctx, feed, rssFeed); err != nil {
+		t.Fatalf("subscribeIfNeeded: %v", err)
+	}
+	first := hub.nextRequest(t)
+	hub.verify(l, first, feed.Url, "challenge")
+
+	// The feed moves to another topic, so the subscription is requested again
+	rssFeed.Self = "http://blog.example.com/atom.xml"
+	if err := l.subscribeIfNeeded(ctx, feed, rssFeed); err != nil {
+		t.Fatalf("subscribeIfNeeded: %v", err)
+	}
+	second := hub.nextRequest(t)
+	if second.Get("hub.secret") == first.Get("hub.secret") {
+		t.Fatal("resubscribing did not send a new secret")
+	}
+
+	sub, err := l.s.db.GetWebSubSubscriptionByFeedID(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("GetWebSubSubscriptionByFeedID: %v", err)
+	}
+	if bytes.Contains(sub.Secret, []byte(second.Get("hub.secret"))) {
+		t.Error("the secret is stored in plain text")
+	}
+	if secret, err := l.secretOf(sub); err != nil || secret != second.Get("hub.secret") {
+		t.Errorf("the stored secret is not the one sent to the hub: %v", err)
+	}
+	if sub.LeaseExpiresAt.Valid {
+		t.Error("the new subscription kept the lease of the old one before being verified")
+	}
+
+	// Content is accepted under the new secret only
+	if rec := hub.verify(l, second, rssFeed.Self, "challenge"); rec.Code != http.StatusOK {
+		t.Fatalf("verify: got status %d", rec.Code)
+	}
+	hub.push(l, second, first.Get("hub.secret"), testRSS([2]string{"Old", "http://blog.example.com/old"}))
+	if _, err := l.s.db.GetPostByUrl(ctx, "http://blog.example.com/old"); err == nil {
+		t.Error("content signed with the old secret was saved")
+	}
+	hub.push(l, second, second.Get("hub.secret"), testRSS([2]string{"New", "http://blog.example.com/new"}))
+	if _, err := l.s.db.GetPostByUrl(ctx, "http://blog.example.com/new"); err != nil {
+		t.Errorf("content signed with the new secret was not saved: %v", err)
+	}
+}
+
+func TestWebSubRenewExpiring(t *testing.T) {
+	ctx := context.Background()
+	hub := newTestHub(t)
+	l, feed, rssFeed := newTestWebSub(t, hub)
+
+	if err := l.subscribeIfNeeded(ctx, feed, rssFeed); err != nil {
+		t.Fatalf("subscribeIfNeeded: %v", err)
+	}
+	form := hub.nextRequest(t)
+	hub.verify(l, form, feed.Url, "challenge")
+
+	// A lease well beyond renewBefore is left alone
+	if err := l.renewExpiring(ctx); err != nil {
+		t.Fatalf("renewExpiring: %v", err)
+	}
+	if len(hub.requests) != 0 {
+		t.Fatal("renewed a subscription whose lease is not expiring")
+	}
+
+	sub, err := l.s.db.GetWebSubSubscriptionByFeedID(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("GetWebSubSubscriptionByFeedID: %v", err)
+	}
+	err = l.s.db.SetWebSubLease(ctx, database.SetWebSubLeaseParams{
+		LeaseExpiresAt: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+		UpdatedAt:      time.Now(),
+		ID:             sub.ID,
+	})
+	if err != nil {
+		t.Fatalf("SetWebSubLease: %v", err)
+	}
+
+	if err := l.renewExpiring(ctx); err != nil {
+		t.Fatalf("renewExpiring: %v", err)
+	}
+	renewal := hub.nextRequest(t)
+	if renewal.Get("hub.callback") != form.Get("hub.callback") || renewal.Get("hub.secret") != form.Get("hub.secret") {
+		t.Errorf("renewal %v does not match the original subscription %v", renewal, form)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// xmlLimits bounds the resources a single feed document may consume while it
+// is parsed, so a hostile feed cannot exhaust the aggregator's memory or CPU.
+type xmlLimits struct {
+	// MaxBytes is the maximum size of the whole document.
+	MaxBytes int64
+	// MaxDepth is the maximum element nesting depth.
+	MaxDepth int
+	// MaxAttrs is the maximum number of attributes on a single element.
+	MaxAttrs int
+	// MaxTokenSize is the maximum size of a single token, such as a start
+	// tag or a run of character data.
+	MaxTokenSize int
+	// MaxItems is the maximum number of items in a feed.
+	MaxItems int
+}
+
+// defaultXMLLimits are the limits applied to every fetched or pushed feed.
+var defaultXMLLimits = xmlLimits{
+	MaxBytes:     64 << 20,
+	MaxDepth:     64,
+	MaxAttrs:     64,
+	MaxTokenSize: 4 << 20,
+	MaxItems:     10000,
+}
+
+// errXMLLimit is returned when a document exceeds one of the xmlLimits or
+// declares entities.
+var errXMLLimit = errors.New("feed rejected by parser limits")
+
+// xmlReadSlack allows for data buffered by the decoder beyond the current
+// token when bounding the input read per token.
+const xmlReadSlack = 64 << 10
+
+// newGuardedDecoder returns an XML decoder that enforces the given limits on
+// the document read from r. Documents declaring entities in their DOCTYPE are
+// rejected.
+func newGuardedDecoder(r io.Reader, limits xmlLimits) *xml.Decoder {
+	input := &boundedReader{
+		r:        r,
+		maxBytes: limits.MaxBytes,
+		maxToken: int64(limits.MaxTokenSize) + xmlReadSlack,
+	}
+	return xml.NewTokenDecoder(&guardedTokenReader{
+		raw:    xml.NewDecoder(input),
+		input:  input,
+		limits: limits,
+	})
+}
+
+// guardedTokenReader reads raw tokens and checks them against the limits
+// before they reach the namespace-aware decoder that wraps it.
+type guardedTokenReader struct {
+	raw    *xml.Decoder
+	input  *boundedReader
+	limits xmlLimits
+	depth  int
+	items  int
+}
+
+// Token returns the next raw token, or an error wrapping errXMLLimit if the
+// token breaks a limit.
+func (g *guardedTokenReader) Token() (xml.Token, error) {
+	tok, err := g.raw.RawToken()
+	if err != nil {
+		return nil, err
+	}
+	g.input.mark(g.raw.InputOffset())
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		g.depth++
+		if g.depth > g.limits.MaxDepth {
+			return nil, fmt.Errorf("%w: elements nested deeper than %d", errXMLLimit, g.limits.MaxDepth)
+		}
+		if len(t.Attr) > g.limits.MaxAttrs {
+			return nil, fmt.Errorf("%w: <%s> has more than %d attributes", errXMLLimit, t.Name.Local, g.limits.MaxAttrs)
+		}
+		for _, attr := range t.Attr {
+			if len(attr.Value) > g.limits.MaxTokenSize {
+				return nil, fmt.Errorf("%w: attribute larger than %d bytes", errXMLLimit, g.limits.MaxTokenSize)
+			}
+		}
+		if t.Name.Local == "item" {
+			g.items++
+			if g.items > g.limits.MaxItems {
+				return nil, fmt.Errorf("%w: more than %d items", errXMLLimit, g.limits.MaxItems)
+			}
+		}
+	case xml.EndElement:
+		g.depth--
+	case xml.CharData:
+		if len(t) > g.limits.MaxTokenSize {
+			return nil, fmt.Errorf("%w: text larger than %d bytes", errXMLLimit, g.limits.MaxTokenSize)
+		}
+	case xml.Comment:
+		if len(t) > g.limits.MaxTokenSize {
+			return nil, fmt.Errorf("%w: comment larger than %d bytes", errXMLLimit, g.limits.MaxTokenSize)
+		}
+	case xml.Directive:
+		if bytes.Contains(t, []byte("<!ENTITY")) {
+			return nil, fmt.Errorf("%w: entity declarations are not allowed", errXMLLimit)
+		}
+	}
+
+	return tok, nil
+}
+
+// boundedReader caps both the total size of the document and the number of
+// bytes read since the last token boundary, so an oversized token fails while
+// it is being read rather than after it has been buffered.
+type boundedReader struct {
+	r        io.Reader
+	maxBytes int64
+	maxToken int64
+	read     int64
+	tokenEnd int64
+}
+
+// mark records the input offset at which the last token ended.
+func (b *boundedReader) mark(offset int64) {
+	b.tokenEnd = offset
+}
+
+// Read reads from the underlying reader without exceeding either bound.
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.read >= b.maxBytes {
+		// Only fail if there is more data than allowed
+		var probe [1]byte
+		if n, err := b.r.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: document larger than %d bytes", errXMLLimit, b.maxBytes)
+	}
+
+	allowed := min(b.maxBytes-b.read, b.tokenEnd+b.maxToken-b.read)
+	if allowed <= 0 {
+		return 0, fmt.Errorf("%w: token larger than %d bytes", errXMLLimit, b.maxToken-xmlReadSlack)
+	}
+	if int64(len(p)) > allowed {
+		p = p[:allowed]
+	}
+
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}